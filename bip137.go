@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BIP-137 header byte ranges. The header is the range start plus the
+// public key recovery id (0-3).
+const (
+	headerP2PKHUncompressed = 27
+	headerP2PKHCompressed   = 31
+	headerP2SHP2WPKH        = 35
+	headerP2WPKH            = 39
+)
+
+// messageHash returns the double SHA-256 of the Bitcoin signed message
+func messageHash(message string) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteVarString(&buf, 0, "Bitcoin Signed Message:\n")
+	_ = wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// signBIP137 creates a compact signature of message with the header byte
+// of the address type. The electrum format uses the P2PKH headers for
+// every address type, the bip137 and trezor formats the BIP-137 ones.
+func signBIP137(btc bitcoin, wif *btcutil.WIF, message, format string) (string, error) {
+	if btc.isTaproot {
+		return "", errors.New("legacy message signatures do not support taproot, use --format simple")
+	}
+
+	sig := ecdsa.SignCompact(wif.PrivKey, messageHash(message), wif.CompressPubKey)
+
+	// SignCompact uses the P2PKH headers, move to the segwit ranges
+	if format != "electrum" && btc.isSegWit {
+		recID := (sig[0] - headerP2PKHUncompressed) & 3
+		if btc.isNative {
+			sig[0] = headerP2WPKH + recID
+		} else {
+			sig[0] = headerP2SHP2WPKH + recID
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// verifyBIP137 recovers the public key of a compact signature of message
+// and checks that it controls address. Both the BIP-137 (Trezor) and the
+// Electrum header conventions are accepted.
+func verifyBIP137(address btcutil.Address, message string, sig []byte) (*btcec.PublicKey, error) {
+	if len(sig) != 65 || sig[0] < headerP2PKHUncompressed || sig[0] > headerP2WPKH+3 {
+		return nil, errors.New("invalid compact signature")
+	}
+
+	header := sig[0]
+	recID := (header - headerP2PKHUncompressed) & 3
+	compressed := header >= headerP2PKHCompressed
+
+	// Recover with the equivalent P2PKH header
+	compact := make([]byte, 65)
+	copy(compact, sig)
+	compact[0] = headerP2PKHUncompressed + recID
+	if compressed {
+		compact[0] += 4
+	}
+	pubKey, _, err := ecdsa.RecoverCompact(compact, messageHash(message))
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover public key: %v", err)
+	}
+
+	pubKeyBytes := pubKey.SerializeUncompressed()
+	if compressed {
+		pubKeyBytes = pubKey.SerializeCompressed()
+	}
+
+	var expected btcutil.Address
+	switch address.(type) {
+	case *btcutil.AddressPubKeyHash:
+		if header >= headerP2SHP2WPKH {
+			return nil, errors.New("signature header is not for a legacy address")
+		}
+		expected, err = btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKeyBytes), &chaincfg.MainNetParams)
+	case *btcutil.AddressScriptHash:
+		if header >= headerP2WPKH || !compressed {
+			return nil, errors.New("signature header is not for a segwit address")
+		}
+		var redeemScript []byte
+		redeemScript, err = nestedWitnessScript(pubKey)
+		if err == nil {
+			expected, err = btcutil.NewAddressScriptHash(redeemScript, &chaincfg.MainNetParams)
+		}
+	case *btcutil.AddressWitnessPubKeyHash:
+		if (header >= headerP2SHP2WPKH && header < headerP2WPKH) || !compressed {
+			return nil, errors.New("signature header is not for a native segwit address")
+		}
+		expected, err = btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKeyBytes), &chaincfg.MainNetParams)
+	default:
+		return nil, errors.New("legacy message signatures only support P2PKH, P2SH-P2WPKH and P2WPKH addresses")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if expected.EncodeAddress() != address.EncodeAddress() {
+		return nil, errors.New("signature was not made by the key of this address")
+	}
+	return pubKey, nil
+}
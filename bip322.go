@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// bip322Tag is the tag of the BIP-322 message hash
+var bip322Tag = []byte("BIP0322-signed-message")
+
+// bip322ToSpend builds the virtual to_spend transaction committing to the
+// message and paying to pkScript
+func bip322ToSpend(pkScript []byte, message string) *wire.MsgTx {
+	messageHash := chainhash.TaggedHash(bip322Tag, []byte(message))
+	sigScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(messageHash[:]).Script()
+
+	tx := wire.NewMsgTx(0)
+	prevOut := wire.NewOutPoint(&chainhash.Hash{}, 0xffffffff)
+	txIn := wire.NewTxIn(prevOut, sigScript, nil)
+	txIn.Sequence = 0
+	tx.AddTxIn(txIn)
+	tx.AddTxOut(wire.NewTxOut(0, pkScript))
+	return tx
+}
+
+// bip322ToSign builds the unsigned virtual to_sign transaction spending
+// the output of toSpend
+func bip322ToSign(toSpend *wire.MsgTx) *wire.MsgTx {
+	tx := wire.NewMsgTx(0)
+	toSpendHash := toSpend.TxHash()
+	txIn := wire.NewTxIn(wire.NewOutPoint(&toSpendHash, 0), nil, nil)
+	txIn.Sequence = 0
+	tx.AddTxIn(txIn)
+	tx.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	return tx
+}
+
+// signBIP322 signs message for the address of wif. The simple format is
+// the base64 witness stack, the full format the base64 to_sign
+// transaction. P2PKH has no witness and is always signed in full format.
+func signBIP322(btc bitcoin, wif *btcutil.WIF, message string, full bool) (string, error) {
+	address, err := btc.getAddress(wif)
+	if err != nil {
+		return "", err
+	}
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return "", err
+	}
+
+	toSpend := bip322ToSpend(pkScript, message)
+	toSign := bip322ToSign(toSpend)
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	if err := signInput(toSign, 0, fetcher, wif); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if full || len(toSign.TxIn[0].Witness) == 0 {
+		if err := toSign.Serialize(&buf); err != nil {
+			return "", err
+		}
+	} else {
+		if err := writeWitness(&buf, toSign.TxIn[0].Witness); err != nil {
+			return "", err
+		}
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// verifyBIP322 verifies a simple or full BIP-322 signature of message
+// by running the to_sign transaction through the script engine.
+func verifyBIP322(address btcutil.Address, message, signature string) error {
+	sigBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return err
+	}
+
+	toSpend := bip322ToSpend(pkScript, message)
+	toSign := bip322ToSign(toSpend)
+
+	// A full signature is a complete to_sign transaction, a simple one
+	// only its witness stack
+	fullTx := wire.NewMsgTx(0)
+	if err := fullTx.Deserialize(bytes.NewReader(sigBytes)); err == nil && len(fullTx.TxIn) > 0 {
+		if len(fullTx.TxIn) != 1 || len(fullTx.TxOut) != 1 ||
+			fullTx.TxIn[0].PreviousOutPoint != toSign.TxIn[0].PreviousOutPoint ||
+			!bytes.Equal(fullTx.TxOut[0].PkScript, toSign.TxOut[0].PkScript) {
+			return errors.New("signature is not a BIP-322 to_sign transaction for this message")
+		}
+		toSign = fullTx
+	} else {
+		witness, err := readWitness(bytes.NewReader(sigBytes))
+		if err != nil {
+			return fmt.Errorf("invalid signature: %v", err)
+		}
+		toSign.TxIn[0].Witness = witness
+
+		// P2SH-P2WPKH needs its redeem script, rebuilt from the witness key
+		if txscript.IsPayToScriptHash(pkScript) && len(witness) == 2 {
+			pubKey, err := btcec.ParsePubKey(witness[1])
+			if err != nil {
+				return fmt.Errorf("invalid signature: %v", err)
+			}
+			redeemScript, err := nestedWitnessScript(pubKey)
+			if err != nil {
+				return err
+			}
+			toSign.TxIn[0].SignatureScript, err = txscript.NewScriptBuilder().AddData(redeemScript).Script()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	engine, err := txscript.NewEngine(pkScript, toSign, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(toSign, fetcher), 0, fetcher)
+	if err != nil {
+		return err
+	}
+	if err := engine.Execute(); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	return nil
+}
+
+// writeWitness serializes a witness stack in consensus encoding
+func writeWitness(buf *bytes.Buffer, witness wire.TxWitness) error {
+	if err := wire.WriteVarInt(buf, 0, uint64(len(witness))); err != nil {
+		return err
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(buf, 0, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readWitness parses a consensus encoded witness stack
+func readWitness(r *bytes.Reader) (wire.TxWitness, error) {
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(r.Len()) {
+		return nil, errors.New("too many witness items")
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(r, 0, wire.MaxMessagePayload, "witness item")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("trailing data after witness")
+	}
+	return witness, nil
+}
+
+// SignMessage signs --message with the --type address of the key given by
+// --custom_private or --custom_mnemonic and --custom_path
+func SignMessage() (*KeyPair, error) {
+	btc, ok := bitcoinByName(*typeFlag)
+	if !ok {
+		return nil, fmt.Errorf("unknown address type %q", *typeFlag)
+	}
+	if customPrivate == "" && customMnemonic == "" {
+		return nil, errors.New("no key given, use --custom_private or --custom_mnemonic")
+	}
+
+	k, err := btc.GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	wif, err := btcutil.DecodeWIF(k.private)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature string
+	switch *formatFlag {
+	case "simple", "full":
+		signature, err = signBIP322(btc, wif, *messageFlag, *formatFlag == "full")
+	case "bip137", "electrum", "trezor":
+		signature, err = signBIP137(btc, wif, *messageFlag, *formatFlag)
+	default:
+		err = fmt.Errorf("unknown signature format %q", *formatFlag)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{
+		network: btc.name,
+		public:  k.public,
+		details: []detail{
+			{"message", *messageFlag},
+			{"signature", signature},
+		},
+	}, nil
+}
+
+// VerifyMessage verifies --signature of --message for --address. Compact
+// 65-byte signatures are verified as BIP-137, everything else as BIP-322.
+func VerifyMessage() (*KeyPair, error) {
+	address, err := btcutil.DecodeAddress(*addressFlag, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", *addressFlag, err)
+	}
+	if *signatureFlag == "" {
+		return nil, errors.New("no signature given, use --signature")
+	}
+
+	k := &KeyPair{
+		network: "bitcoin",
+		public:  address.EncodeAddress(),
+	}
+
+	sigBytes, err := base64.StdEncoding.DecodeString(*signatureFlag)
+	if err == nil && len(sigBytes) == 65 && sigBytes[0] >= headerP2PKHUncompressed && sigBytes[0] <= headerP2WPKH+3 {
+		pubKey, err := verifyBIP137(address, *messageFlag, sigBytes)
+		if err != nil {
+			return nil, err
+		}
+		k.details = []detail{
+			{"format", "bip137"},
+			{"pubkey", hex.EncodeToString(pubKey.SerializeCompressed())},
+		}
+	} else {
+		if err := verifyBIP322(address, *messageFlag, *signatureFlag); err != nil {
+			return nil, err
+		}
+		k.details = []detail{{"format", "bip322"}}
+	}
+
+	k.details = append(k.details, detail{"signature", "valid"})
+	return k, nil
+}
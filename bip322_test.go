@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// TestBIP322 checks the simple signature test vectors of BIP-322
+func TestBIP322(t *testing.T) {
+	wif, err := btcutil.DecodeWIF("L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := btcutil.DecodeAddress("bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		message   string
+		signature string
+	}{
+		{"", "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI="},
+		{"Hello World", "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI="},
+	}
+	for _, tt := range tests {
+		if err := verifyBIP322(address, tt.message, tt.signature); err != nil {
+			t.Errorf("verify %q: %v", tt.message, err)
+		}
+		// Bitcoin Core grinds for a low R, so the signatures differ from the
+		// vectors but must verify
+		signature, err := signBIP322(btcMap["native"], wif, tt.message, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyBIP322(address, tt.message, signature); err != nil {
+			t.Errorf("verify own signature of %q: %v", tt.message, err)
+		}
+	}
+
+	// The signature of one message does not verify another
+	if err := verifyBIP322(address, "Hello World", tests[0].signature); err == nil {
+		t.Error("verified the signature of another message")
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+	"golang.org/x/text/unicode/norm"
+)
+
+// BIP-38 prefixes and flag bits
+var (
+	bip38NonECPrefix        = []byte{0x01, 0x42}
+	bip38ECPrefix           = []byte{0x01, 0x43}
+	bip38IntermediatePrefix = []byte{0x2c, 0xe9, 0xb3, 0xe1, 0xff, 0x39, 0xe2}
+	bip38ConfirmPrefix      = []byte{0x64, 0x3b, 0xf6, 0xa8, 0x9a}
+)
+
+const (
+	bip38FlagNonEC      = 0xc0
+	bip38FlagCompressed = 0x20
+	bip38FlagLot        = 0x04
+	bip38MagicLot       = 0x51
+	bip38MagicNoLot     = 0x53
+)
+
+// cachedPassphrases caches the answer of each prompt so the vanity loop
+// prompts once. Prompts for an existing and a new secret differ, so one is
+// never reused for the other.
+var cachedPassphrases = map[string]string{}
+
+// stdinReader reads the passphrases piped to stdin, one line each
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassphrase prompts for a passphrase on the terminal without echo.
+// When stdin is not a terminal a line is read instead. With confirm the
+// passphrase is asked twice.
+func readPassphrase(prompt string, confirm bool) (string, error) {
+	if passphrase, ok := cachedPassphrases[prompt]; ok {
+		return passphrase, nil
+	}
+
+	read := func(prompt string) (string, error) {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			line, err := stdinReader.ReadString('\n')
+			if err != nil && line == "" {
+				return "", fmt.Errorf("failed to read passphrase: %v", err)
+			}
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+		fmt.Fprint(os.Stderr, prompt)
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %v", err)
+		}
+		return string(passphrase), nil
+	}
+
+	passphrase, err := read(prompt)
+	if err != nil {
+		return "", err
+	}
+	if confirm && term.IsTerminal(int(os.Stdin.Fd())) {
+		again, err := read("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	cachedPassphrases[prompt] = passphrase
+	return passphrase, nil
+}
+
+// readBIP38Passphrase reads a passphrase, NFC normalized as BIP-38 requires.
+// With confirm it is a new passphrase to encrypt with.
+func readBIP38Passphrase(confirm bool) (string, error) {
+	prompt := "BIP-38 passphrase: "
+	if confirm {
+		prompt = "New BIP-38 passphrase: "
+	}
+	passphrase, err := readPassphrase(prompt, confirm)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("empty passphrase")
+	}
+	return norm.NFC.String(passphrase), nil
+}
+
+// isBIP38 reports whether key looks like a BIP-38 encrypted key
+func isBIP38(key string) bool {
+	return len(key) == 58 && strings.HasPrefix(key, "6P")
+}
+
+// bip38AddressHash is the first 4 bytes of SHA256(SHA256(address)) of the
+// P2PKH address of pubKey
+func bip38AddressHash(pubKey *btcec.PublicKey, compressed bool) ([]byte, string, error) {
+	pubKeyBytes := pubKey.SerializeUncompressed()
+	if compressed {
+		pubKeyBytes = pubKey.SerializeCompressed()
+	}
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKeyBytes), &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, "", err
+	}
+	return chainhash.DoubleHashB([]byte(addr.EncodeAddress()))[:4], addr.EncodeAddress(), nil
+}
+
+// xorBlock returns a XOR b for 16-byte blocks
+func xorBlock(a, b []byte) []byte {
+	result := make([]byte, 16)
+	for i := range result {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
+
+// aesBlock encrypts (or decrypts) a single 16-byte block with AES-256
+func aesBlock(key, block []byte, decrypt bool) []byte {
+	cipher, _ := aes.NewCipher(key)
+	result := make([]byte, 16)
+	if decrypt {
+		cipher.Decrypt(result, block)
+	} else {
+		cipher.Encrypt(result, block)
+	}
+	return result
+}
+
+// encryptBIP38 encrypts wif with passphrase without EC multiplication
+func encryptBIP38(wif *btcutil.WIF, passphrase string) (string, error) {
+	addressHash, _, err := bip38AddressHash(wif.PrivKey.PubKey(), wif.CompressPubKey)
+	if err != nil {
+		return "", err
+	}
+	derived, err := scrypt.Key([]byte(passphrase), addressHash, 16384, 8, 8, 64)
+	if err != nil {
+		return "", err
+	}
+
+	key := wif.PrivKey.Serialize()
+	flag := byte(bip38FlagNonEC)
+	if wif.CompressPubKey {
+		flag |= bip38FlagCompressed
+	}
+	payload := append([]byte{bip38NonECPrefix[1], flag}, addressHash...)
+	payload = append(payload, aesBlock(derived[32:], xorBlock(key[:16], derived[:16]), false)...)
+	payload = append(payload, aesBlock(derived[32:], xorBlock(key[16:], derived[16:32]), false)...)
+	return base58.CheckEncode(payload, bip38NonECPrefix[0]), nil
+}
+
+// decryptBIP38 decrypts a BIP-38 key, EC-multiplied or not, to a WIF
+func decryptBIP38(encrypted, passphrase string) (*btcutil.WIF, error) {
+	payload, version, err := base58.CheckDecode(encrypted)
+	if err != nil || version != 0x01 || len(payload) != 38 {
+		return nil, errors.New("invalid BIP-38 key")
+	}
+	flag := payload[1]
+	compressed := flag&bip38FlagCompressed != 0
+	addressHash := payload[2:6]
+
+	var privKey *btcec.PrivateKey
+	switch payload[0] {
+	case bip38NonECPrefix[1]:
+		if flag&bip38FlagNonEC != bip38FlagNonEC {
+			return nil, errors.New("invalid BIP-38 flag byte")
+		}
+		derived, err := scrypt.Key([]byte(passphrase), addressHash, 16384, 8, 8, 64)
+		if err != nil {
+			return nil, err
+		}
+		key := append(xorBlock(aesBlock(derived[32:], payload[6:22], true), derived[:16]),
+			xorBlock(aesBlock(derived[32:], payload[22:38], true), derived[16:32])...)
+		privKey, _ = btcec.PrivKeyFromBytes(key)
+
+	case bip38ECPrefix[1]:
+		ownerEntropy := payload[6:14]
+		passFactor, err := bip38PassFactor(passphrase, ownerEntropy, flag&bip38FlagLot != 0)
+		if err != nil {
+			return nil, err
+		}
+		passPoint := scalarBaseMult(passFactor).SerializeCompressed()
+		derived, err := scrypt.Key(passPoint, append(append([]byte{}, addressHash...), ownerEntropy...), 1024, 1, 1, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		// encryptedpart2 holds the second half of encryptedpart1
+		part2 := xorBlock(aesBlock(derived[32:], payload[22:38], true), derived[16:32])
+		part1 := append(append([]byte{}, payload[14:22]...), part2[:8]...)
+		seedB := append(xorBlock(aesBlock(derived[32:], part1, true), derived[:16]), part2[8:]...)
+
+		factorB := new(btcec.ModNScalar)
+		factorB.SetByteSlice(chainhash.DoubleHashB(seedB))
+		privKey = btcec.PrivKeyFromScalar(factorB.Mul(passFactor))
+
+	default:
+		return nil, errors.New("invalid BIP-38 key")
+	}
+
+	expected, _, err := bip38AddressHash(privKey.PubKey(), compressed)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(expected, addressHash) {
+		return nil, errors.New("wrong BIP-38 passphrase")
+	}
+	return btcutil.NewWIF(privKey, &chaincfg.MainNetParams, compressed)
+}
+
+// bip38PassFactor derives the EC-multiply passfactor from passphrase and
+// the owner entropy (owner salt, or salt and lot-sequence number)
+func bip38PassFactor(passphrase string, ownerEntropy []byte, lot bool) (*btcec.ModNScalar, error) {
+	ownerSalt := ownerEntropy
+	if lot {
+		ownerSalt = ownerEntropy[:4]
+	}
+	preFactor, err := scrypt.Key([]byte(passphrase), ownerSalt, 16384, 8, 8, 32)
+	if err != nil {
+		return nil, err
+	}
+	passFactor := preFactor
+	if lot {
+		passFactor = chainhash.DoubleHashB(append(preFactor, ownerEntropy...))
+	}
+
+	s := new(btcec.ModNScalar)
+	if s.SetByteSlice(passFactor) || s.IsZero() {
+		return nil, errors.New("passphrase gives an invalid passfactor, use another owner salt")
+	}
+	return s, nil
+}
+
+// flagGiven reports whether the flag name was set on the command line
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// BIP38Intermediate creates an intermediate passphrase code from the
+// passphrase, with the --lot and --sequence numbers when a lot is given.
+// Lot 0 is a valid lot, so lot mode depends on --lot being given.
+func BIP38Intermediate() error {
+	if *lotFlag < 0 || *lotFlag > 1048575 || *sequenceFlag < 0 || *sequenceFlag > 4095 {
+		return errors.New("lot must be 0 to 1048575 and sequence 0 to 4095")
+	}
+	hasLot := flagGiven("lot")
+	if flagGiven("sequence") && !hasLot {
+		return errors.New("--sequence needs a --lot")
+	}
+	passphrase, err := readBIP38Passphrase(true)
+	if err != nil {
+		return err
+	}
+
+	ownerEntropy := make([]byte, 8)
+	if _, err := rand.Read(ownerEntropy); err != nil {
+		return err
+	}
+	magic := byte(bip38MagicNoLot)
+	if hasLot {
+		binary.BigEndian.PutUint32(ownerEntropy[4:], uint32(*lotFlag*4096+*sequenceFlag))
+		magic = bip38MagicLot
+	}
+
+	passFactor, err := bip38PassFactor(passphrase, ownerEntropy, hasLot)
+	if err != nil {
+		return err
+	}
+	payload := append(append([]byte{}, bip38IntermediatePrefix[1:]...), magic)
+	payload = append(payload, ownerEntropy...)
+	payload = append(payload, scalarBaseMult(passFactor).SerializeCompressed()...)
+
+	fmt.Printf("%-3s %-12s %s\n", "bip38", "intermediate", base58.CheckEncode(payload, bip38IntermediatePrefix[0]))
+	if hasLot {
+		fmt.Printf("%-3s %-12s %d\n", "bip38", "lot", *lotFlag)
+		fmt.Printf("%-3s %-12s %d\n", "bip38", "sequence", *sequenceFlag)
+	}
+	return nil
+}
+
+// BIP38Generate creates a new encrypted key and its confirmation code from
+// the intermediate code --code without learning the private key
+func BIP38Generate() (*KeyPair, error) {
+	payload, version, err := base58.CheckDecode(*codeFlag)
+	if err != nil || version != bip38IntermediatePrefix[0] || len(payload) != 48 ||
+		!bytes.Equal(payload[:6], bip38IntermediatePrefix[1:]) ||
+		(payload[6] != bip38MagicLot && payload[6] != bip38MagicNoLot) {
+		return nil, errors.New("invalid intermediate code")
+	}
+	ownerEntropy := payload[7:15]
+	passPoint, err := btcec.ParsePubKey(payload[15:48])
+	if err != nil {
+		return nil, fmt.Errorf("invalid intermediate code: %v", err)
+	}
+
+	compressed := !*uncompressedFlag
+	flag := byte(0)
+	if compressed {
+		flag |= bip38FlagCompressed
+	}
+	if payload[6] == bip38MagicLot {
+		flag |= bip38FlagLot
+	}
+
+	// factorb from random seedb, the address key is passpoint * factorb
+	seedB := make([]byte, 24)
+	factorB := new(btcec.ModNScalar)
+	for {
+		if _, err := rand.Read(seedB); err != nil {
+			return nil, err
+		}
+		if !factorB.SetByteSlice(chainhash.DoubleHashB(seedB)) && !factorB.IsZero() {
+			break
+		}
+	}
+	var point, result btcec.JacobianPoint
+	passPoint.AsJacobian(&point)
+	btcec.ScalarMultNonConst(factorB, &point, &result)
+	result.ToAffine()
+	pubKey := btcec.NewPublicKey(&result.X, &result.Y)
+
+	addressHash, address, err := bip38AddressHash(pubKey, compressed)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, addressHash...), ownerEntropy...)
+	derived, err := scrypt.Key(payload[15:48], salt, 1024, 1, 1, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	part1 := aesBlock(derived[32:], xorBlock(seedB[:16], derived[:16]), false)
+	part2 := aesBlock(derived[32:], xorBlock(append(append([]byte{}, part1[8:]...), seedB[16:]...), derived[16:32]), false)
+	key := append([]byte{bip38ECPrefix[1], flag}, salt...)
+	key = append(key, part1[:8]...)
+	key = append(key, part2...)
+
+	// The confirmation code holds pointb = G * factorb encrypted
+	pointB := scalarBaseMult(factorB).SerializeCompressed()
+	confirm := append(append([]byte{}, bip38ConfirmPrefix[1:]...), flag)
+	confirm = append(confirm, salt...)
+	confirm = append(confirm, pointB[0]^(derived[63]&0x01))
+	confirm = append(confirm, aesBlock(derived[32:], xorBlock(pointB[1:17], derived[:16]), false)...)
+	confirm = append(confirm, aesBlock(derived[32:], xorBlock(pointB[17:], derived[16:32]), false)...)
+
+	k := &KeyPair{
+		network:   "bip38",
+		public:    address,
+		private:   base58.CheckEncode(key, bip38ECPrefix[0]),
+		keyFormat: "compressed",
+		details:   []detail{{"confirmation", base58.CheckEncode(confirm, bip38ConfirmPrefix[0])}},
+	}
+	if !compressed {
+		k.keyFormat = "uncompressed"
+	}
+	return k, nil
+}
+
+// BIP38Confirm checks the confirmation code --code with the passphrase and
+// returns the address the encrypted key belongs to
+func BIP38Confirm() (*KeyPair, error) {
+	payload, version, err := base58.CheckDecode(*codeFlag)
+	if err != nil || version != bip38ConfirmPrefix[0] || len(payload) != 50 ||
+		!bytes.Equal(payload[:4], bip38ConfirmPrefix[1:]) {
+		return nil, errors.New("invalid confirmation code")
+	}
+	flag := payload[4]
+	compressed := flag&bip38FlagCompressed != 0
+	addressHash := payload[5:9]
+	ownerEntropy := payload[9:17]
+	encryptedPointB := payload[17:50]
+
+	passphrase, err := readBIP38Passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	passFactor, err := bip38PassFactor(passphrase, ownerEntropy, flag&bip38FlagLot != 0)
+	if err != nil {
+		return nil, err
+	}
+	passPoint := scalarBaseMult(passFactor).SerializeCompressed()
+	derived, err := scrypt.Key(passPoint, payload[5:17], 1024, 1, 1, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	pointB := []byte{encryptedPointB[0] ^ (derived[63] & 0x01)}
+	pointB = append(pointB, xorBlock(aesBlock(derived[32:], encryptedPointB[1:17], true), derived[:16])...)
+	pointB = append(pointB, xorBlock(aesBlock(derived[32:], encryptedPointB[17:], true), derived[16:32])...)
+	pubKeyB, err := btcec.ParsePubKey(pointB)
+	if err != nil {
+		return nil, errors.New("wrong BIP-38 passphrase")
+	}
+
+	var point, result btcec.JacobianPoint
+	pubKeyB.AsJacobian(&point)
+	btcec.ScalarMultNonConst(passFactor, &point, &result)
+	result.ToAffine()
+	expected, address, err := bip38AddressHash(btcec.NewPublicKey(&result.X, &result.Y), compressed)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(expected, addressHash) {
+		return nil, errors.New("wrong BIP-38 passphrase")
+	}
+
+	k := &KeyPair{
+		network: "bip38",
+		public:  address,
+		details: []detail{{"confirmation", "valid"}},
+	}
+	if flag&bip38FlagLot != 0 {
+		lotSequence := binary.BigEndian.Uint32(ownerEntropy[4:])
+		k.details = append(k.details,
+			detail{"lot", fmt.Sprint(lotSequence / 4096)},
+			detail{"sequence", fmt.Sprint(lotSequence % 4096)},
+		)
+	}
+	return k, nil
+}
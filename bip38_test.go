@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// bip38Vectors are the test vectors of BIP-38
+var bip38Vectors = []struct {
+	name       string
+	encrypted  string
+	passphrase string
+	wif        string
+	ecMultiply bool
+}{
+	{"no compression", "6PRVWUbkzzsbcVac2qwfssoUJAN1Xhrg6bNk8J7Nzm5H7kxEbn2Nh2ZoGg", "TestingOneTwoThree", "5KN7MzqK5wt2TP1fQCYyHBtDrXdJuXbUzm4A9rKAteGu3Qi5CVR", false},
+	{"no compression", "6PRNFFkZc2NZ6dJqFfhRoFNMR9Lnyj7dYGrzdgXXVMXcxoKTePPX1dWByq", "Satoshi", "5HtasZ6ofTHP6HCwTqTkLDuLQisYPah7aUnSKfC7h4hMUVw2gi5", false},
+	{"compression", "6PYNKZ1EAgYgmQfmNVamxyXVWHzK5s6DGhwP4J5o44cvXdoY7sRzhtpUeo", "TestingOneTwoThree", "L44B5gGEpqEDRS9vVPz7QT35jcBG2r3CZwSwQ4fCewXAhAhqGVpP", false},
+	{"compression", "6PYLtMnXvfG3oJde97zRyLYFZCYizPU5T3LwgdYJz1fRhh16bU7u6PPmY7", "Satoshi", "KwYgW8gcxj1JWJXhPSu4Fqwzfhp5Yfi42mdYmMa4XqK7NJxXUSK7", false},
+	{"ec multiply", "6PfQu77ygVyJLZjfvMLyhLMQbYnu5uguoJJ4kMCLqWwPEdfpwANVS76gTX", "TestingOneTwoThree", "5K4caxezwjGCGfnoPTZ8tMcJBLB7Jvyjv4xxeacadhq8nLisLR2", true},
+	{"ec multiply", "6PfLGnQs6VZnrNpmVKfjotbnQuaJK4KZoPFrAjx1JMJUa1Ft8gnf5WxfKd", "Satoshi", "5KJ51SgxWaAYR13zd9ReMhJpwrcX47xTJh2D3fGPG9CM8vkv5sH", true},
+	{"ec multiply lot", "6PgNBNNzDkKdhkT6uJntUXwwzQV8Rr2tZcbkDcuC9DZRsS6AtHts4Ypo1j", "MOLON LABE", "5JLdxTtcTHcfYcmJsNVy1v2PMDx432JPoYcBTVVRHpPaxUrdtf8", true},
+	{"ec multiply lot", "6PgGWtx25kUg8QWvwuJAgorN6k9FbE25rv5dMRwu5SKMnfpfVe5mar2ngH", "ΜΟΛΩΝ ΛΑΒΕ", "5KMKKuUmAkiNbA3DazMQiLfDq47qs8MAEThm4yL8R2PhV1ov33D", true},
+}
+
+func TestDecryptBIP38(t *testing.T) {
+	for _, v := range bip38Vectors {
+		wif, err := decryptBIP38(v.encrypted, v.passphrase)
+		if err != nil {
+			t.Errorf("%s %s: %v", v.name, v.encrypted, err)
+			continue
+		}
+		if wif.String() != v.wif {
+			t.Errorf("%s %s: decrypted %s, want %s", v.name, v.encrypted, wif, v.wif)
+		}
+	}
+}
+
+func TestEncryptBIP38(t *testing.T) {
+	for _, v := range bip38Vectors {
+		// EC-multiplied keys are encrypted from an intermediate code
+		if v.ecMultiply {
+			continue
+		}
+		wif, err := btcutil.DecodeWIF(v.wif)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, err := encryptBIP38(wif, v.passphrase)
+		if err != nil {
+			t.Errorf("%s %s: %v", v.name, v.wif, err)
+			continue
+		}
+		if encrypted != v.encrypted {
+			t.Errorf("%s %s: encrypted %s, want %s", v.name, v.wif, encrypted, v.encrypted)
+		}
+	}
+}
+
+func TestDecryptBIP38WrongPassphrase(t *testing.T) {
+	if _, err := decryptBIP38(bip38Vectors[0].encrypted, "wrong"); err == nil {
+		t.Error("decrypted with a wrong passphrase")
+	}
+}
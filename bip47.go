@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// BIP-47 account path and payment code version byte
+const (
+	paymentCodePath    = "m/47'/0'/0'"
+	paymentCodeVersion = 0x47
+)
+
+type paymentCode struct{}
+
+func (pc paymentCode) Name() string {
+	return "Payment Code"
+}
+
+// encodePaymentCode serializes a version 1 payment code of the account
+// public key and chain code
+func encodePaymentCode(pubKey, chainCode []byte) string {
+	payload := make([]byte, 80)
+	payload[0] = 0x01 // version
+	payload[1] = 0x00 // features, no bitmessage
+	copy(payload[2:35], pubKey)
+	copy(payload[35:67], chainCode)
+	return base58.CheckEncode(payload, paymentCodeVersion)
+}
+
+// decodePaymentCode parses a version 1 payment code into the public
+// account key it encodes
+func decodePaymentCode(code string) (*bip32.Key, error) {
+	payload, version, err := base58.CheckDecode(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment code: %v", err)
+	}
+	if version != paymentCodeVersion || len(payload) != 80 || payload[0] != 0x01 {
+		return nil, errors.New("invalid payment code: only version 1 is supported")
+	}
+	if _, err := btcec.ParsePubKey(payload[2:35]); err != nil {
+		return nil, fmt.Errorf("invalid payment code: %v", err)
+	}
+	// Full slice expressions, child derivation appends to the key
+	return &bip32.Key{
+		Version:   bip32.PublicWalletVersion,
+		Key:       payload[2:35:35],
+		ChainCode: payload[35:67:67],
+	}, nil
+}
+
+// paymentCodeChild returns the public key at index i of a payment code
+func paymentCodeChild(account *bip32.Key, i uint32) (*btcec.PublicKey, error) {
+	child, err := account.NewChildKey(i)
+	if err != nil {
+		return nil, err
+	}
+	return btcec.ParsePubKey(child.Key)
+}
+
+// sharedSecret returns the BIP-47 scalar SHA256(x(priv * pub))
+func sharedSecret(privKey *btcec.PrivateKey, pubKey *btcec.PublicKey) (*btcec.ModNScalar, error) {
+	var point, result btcec.JacobianPoint
+	pubKey.AsJacobian(&point)
+	btcec.ScalarMultNonConst(&privKey.Key, &point, &result)
+	result.ToAffine()
+	x := result.X.Bytes()
+
+	s := new(btcec.ModNScalar)
+	hash := sha256.Sum256(x[:])
+	if s.SetBytes(&hash) != 0 || s.IsZero() {
+		return nil, errors.New("shared secret is not a valid scalar, use the next index")
+	}
+	return s, nil
+}
+
+// p2pkhAddress returns the legacy address of a compressed public key
+func p2pkhAddress(pubKey *btcec.PublicKey) (string, error) {
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), &chaincfg.MainNetParams)
+	if err != nil {
+		return "", err
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// GenerateKeys derives the payment code and notification address of
+// --custom_mnemonic, or of a new mnemonic. With --payment_code the shared
+// send and receive addresses with that counterparty at --index are added.
+func (pc paymentCode) GenerateKeys() (*KeyPair, error) {
+	mnemonic := customMnemonic
+	if mnemonic == "" {
+		entropy, err := bip39.NewEntropy(256)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			return nil, err
+		}
+	} else if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("invalid mnemonic phrase")
+	}
+	path := paymentCodePath
+	if customPath != "" {
+		path = customPath
+	}
+	if *indexFlag < 0 || *indexFlag >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("invalid index %d", *indexFlag)
+	}
+	index := uint32(*indexFlag)
+
+	masterKey, err := bip32.NewMasterKey(bip39.NewSeed(mnemonic, ""))
+	if err != nil {
+		return nil, err
+	}
+	account, err := btcMap["legacy"].deriveChildKeyFromMaster(masterKey, path)
+	if err != nil {
+		return nil, err
+	}
+	notification, err := account.NewChildKey(0)
+	if err != nil {
+		return nil, err
+	}
+	notificationKey, _ := btcec.PrivKeyFromBytes(notification.Key)
+	notificationAddress, err := p2pkhAddress(notificationKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+
+	k := &KeyPair{
+		network: "payment code",
+		public:  encodePaymentCode(account.PublicKey().Key, account.ChainCode),
+		details: []detail{{"notification", notificationAddress}},
+	}
+	if *infoFlag || *infoLongFlag {
+		k.mnemonic = mnemonic
+		k.derivationPath = path
+		if k.origin, err = newKeyOrigin(masterKey, path); err != nil {
+			return nil, err
+		}
+	}
+	if *paymentCodeFlag == "" {
+		return k, nil
+	}
+
+	counterparty, err := decodePaymentCode(*paymentCodeFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send: our notification key with their key at index
+	theirKey, err := paymentCodeChild(counterparty, index)
+	if err != nil {
+		return nil, err
+	}
+	s, err := sharedSecret(notificationKey, theirKey)
+	if err != nil {
+		return nil, err
+	}
+	sendAddress, err := p2pkhAddress(addPoints(theirKey, scalarBaseMult(s)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Receive: our key at index with their notification key
+	theirNotification, err := paymentCodeChild(counterparty, 0)
+	if err != nil {
+		return nil, err
+	}
+	child, err := account.NewChildKey(index)
+	if err != nil {
+		return nil, err
+	}
+	ourKey, _ := btcec.PrivKeyFromBytes(child.Key)
+	s, err = sharedSecret(ourKey, theirNotification)
+	if err != nil {
+		return nil, err
+	}
+	receiveKey := btcec.PrivKeyFromScalar(s.Add(&ourKey.Key))
+	receiveAddress, err := p2pkhAddress(receiveKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+	receiveWIF, err := btcutil.NewWIF(receiveKey, &chaincfg.MainNetParams, true)
+	if err != nil {
+		return nil, err
+	}
+
+	k.details = append(k.details,
+		detail{"send " + fmt.Sprint(index), sendAddress},
+		detail{"receive " + fmt.Sprint(index), receiveAddress},
+		detail{"receive key", receiveWIF.String()},
+	)
+	return k, nil
+}
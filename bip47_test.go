@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BIP-47 test vectors of Alice and Bob
+const (
+	bip47AliceMnemonic     = "response seminar brave tip suit recall often sound stick owner lottery motion"
+	bip47AliceCode         = "PM8TJTLJbPRGxSbc8EJi42Wrr6QbNSaSSVJ5Y3E4pbCYiTHUskHg13935Ubb7q8tx9GVbh2UuRnBc3WSyJHhUrw8KhprKnn9eDznYGieTzFcwQRya4GA"
+	bip47AliceNotification = "1JDdmqFLhpzcUwPeinhJbUPw4Co3aWLyzW"
+	bip47BobMnemonic       = "reward upper indicate eight swift arch injury crystal super wrestle already dentist"
+	bip47BobCode           = "PM8TJS2JxQ5ztXUpBBRnpTbcUXbUHy2T1abfrb3KkAAtMEGNbey4oumH7Hc578WgQJhPjBxteQ5GHHToTYHE3A1w6p7tU6KSoFmWBVbFGjKPisZDbP97"
+	bip47BobNotification   = "1ChvUUvht2hUQufHBXF8NgLhW8SwE2ecGV"
+)
+
+// paymentCodeKeys returns the payment code key pair of mnemonic with the
+// counterparty code at index
+func paymentCodeKeys(t *testing.T, mnemonic, code string, index int) *KeyPair {
+	oldMnemonic, oldCode, oldIndex := customMnemonic, *paymentCodeFlag, *indexFlag
+	t.Cleanup(func() {
+		customMnemonic, *paymentCodeFlag, *indexFlag = oldMnemonic, oldCode, oldIndex
+	})
+	customMnemonic, *paymentCodeFlag, *indexFlag = mnemonic, code, index
+
+	k, err := paymentCode{}.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// detailValue returns the value of the detail label of k
+func detailValue(k *KeyPair, label string) string {
+	for _, d := range k.details {
+		if d.label == label {
+			return d.value
+		}
+	}
+	return ""
+}
+
+func TestPaymentCode(t *testing.T) {
+	tests := []struct {
+		mnemonic, code, notification string
+	}{
+		{bip47AliceMnemonic, bip47AliceCode, bip47AliceNotification},
+		{bip47BobMnemonic, bip47BobCode, bip47BobNotification},
+	}
+	for _, tt := range tests {
+		k := paymentCodeKeys(t, tt.mnemonic, "", 0)
+		if k.public != tt.code {
+			t.Errorf("payment code %s, want %s", k.public, tt.code)
+		}
+		if n := detailValue(k, "notification"); n != tt.notification {
+			t.Errorf("notification address %s, want %s", n, tt.notification)
+		}
+	}
+}
+
+// TestPaymentCodeShared checks the addresses Alice sends Bob to, which Bob
+// receives on
+func TestPaymentCodeShared(t *testing.T) {
+	addresses := []string{
+		"141fi7TY3h936vRUKh1qfUZr8rSBuYbVBK",
+		"12u3Uued2fuko2nY4SoSFGCoGLCBUGPkk6",
+		"1FsBVhT5dQutGwaPePTYMe5qvYqqjxyftc",
+	}
+	for i, want := range addresses {
+		alice := paymentCodeKeys(t, bip47AliceMnemonic, bip47BobCode, i)
+		if send := detailValue(alice, "send "+fmt.Sprint(i)); send != want {
+			t.Errorf("Alice send %d: %s, want %s", i, send, want)
+		}
+		bob := paymentCodeKeys(t, bip47BobMnemonic, bip47AliceCode, i)
+		if receive := detailValue(bob, "receive "+fmt.Sprint(i)); receive != want {
+			t.Errorf("Bob receive %d: %s, want %s", i, receive, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/tyler-smith/go-bip32"
 	"github.com/tyler-smith/go-bip39"
 )
@@ -36,19 +38,10 @@ var btcMap = map[string]bitcoin{
 }
 
 func (btc bitcoin) getParams() *chaincfg.Params {
-	param := &chaincfg.MainNetParams // Always use mainnet
-	if btc.isSegWit {
-		if btc.isNative {
-			param.Bech32HRPSegwit = "bc" // Native SegWit Bech32 address prefix (mainnet)
-		} else {
-			param.PubKeyHashAddrID = 0x05 // SegWit P2SH address prefix (mainnet)
-		}
-		param.PrivateKeyID = 0x80 // SegWit private key prefix (mainnet)
-	} else {
-		param.PubKeyHashAddrID = 0x00 // Legacy P2PKH address prefix (mainnet)
-		param.PrivateKeyID = 0x80     // Legacy private key prefix (mainnet)
-	}
-	return param
+	// Always use mainnet. Every address type shares the mainnet prefixes
+	// (P2PKH 0x00, P2SH 0x05, bech32 "bc", WIF 0x80), so the global params
+	// are never modified.
+	return &chaincfg.MainNetParams
 }
 
 func (btc bitcoin) createPrivateKey() (*btcutil.WIF, error) {
@@ -59,11 +52,34 @@ func (btc bitcoin) createPrivateKey() (*btcutil.WIF, error) {
 	}
 
 	// Ensure it's for mainnet (not testnet) with the correct prefix
-	return btcutil.NewWIF(secret, btc.getParams(), true)
+	return btcutil.NewWIF(secret, btc.getParams(), btc.compressed())
+}
+
+// compressed reports whether new keys use the compressed public key form.
+// Only legacy addresses can be built from an uncompressed key.
+func (btc bitcoin) compressed() bool {
+	return btc.isSegWit || !*uncompressedFlag
+}
+
+// keyFormat names the public key form used for the address of wif
+func keyFormat(wif *btcutil.WIF) string {
+	if wif.CompressPubKey {
+		return "compressed"
+	}
+	return "uncompressed"
 }
 
 func (btc bitcoin) getAddress(wif *btcutil.WIF) (btcutil.Address, error) {
-	pubKey := wif.PrivKey.PubKey()
+	return btc.pubKeyAddress(wif.PrivKey.PubKey(), wif.CompressPubKey)
+}
+
+// pubKeyAddress returns the address of pubKey, hashed in compressed or
+// uncompressed form for legacy addresses
+func (btc bitcoin) pubKeyAddress(pubKey *btcec.PublicKey, compressed bool) (btcutil.Address, error) {
+	// SegWit outputs only allow compressed public keys
+	if btc.isSegWit && !compressed {
+		return nil, errors.New("segwit addresses require a compressed private key")
+	}
 
 	// Generate Taproot address (starts with 'bc1p')
 	if btc.isTaproot {
@@ -90,28 +106,51 @@ func (btc bitcoin) getAddress(wif *btcutil.WIF) (btcutil.Address, error) {
 
 	// Generate Segwit integrated withness (starts with '3')
 	if btc.isSegWit {
-		addr, err := btcutil.NewAddressScriptHash(btcutil.Hash160(pubKey.SerializeCompressed()), btc.getParams())
+		redeemScript, err := nestedWitnessScript(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := btcutil.NewAddressScriptHash(redeemScript, btc.getParams())
 		if err != nil {
 			return nil, err
 		}
 		return addr, nil
 	}
 
-	// Otherwise, generate a legacy P2PKH address (starts with '1')
-	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), btc.getParams())
+	// Otherwise, generate a legacy P2PKH address (starts with '1'),
+	// hashing the public key in the form the WIF was encoded with
+	serialized := pubKey.SerializeUncompressed()
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	}
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(serialized), btc.getParams())
 	if err != nil {
 		return nil, err
 	}
 	return addr, nil
 }
 
+// nestedWitnessScript returns the P2SH redeem script wrapping the P2WPKH
+// output of pubKey, the witness program 0 <hash160(pubKey)>
+func nestedWitnessScript(pubKey *btcec.PublicKey) ([]byte, error) {
+	witnessAddr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(witnessAddr)
+}
+
 func (btc bitcoin) GenerateKeys() (*KeyPair, error) {
 	if btc.name == "" {
 		return nil, errors.New("network not found")
 	}
+	if *uncompressedFlag && btc.isSegWit {
+		return nil, errors.New("uncompressed keys are only supported for legacy addresses")
+	}
 
 	var privateKey *btcutil.WIF
 	var mnemonic string
+	var origin *keyOrigin
 	var err error
 
 	// Check for custom private key first
@@ -135,6 +174,11 @@ func (btc bitcoin) GenerateKeys() (*KeyPair, error) {
 		}
 	} else if customMnemonic != "" {
 		mnemonic = customMnemonic
+		if !bip39.IsMnemonicValid(mnemonic) {
+			if seedType := electrumSeedType(mnemonic); seedType != "" {
+				return nil, fmt.Errorf("this is an Electrum %s seed, use the electrum network", seedType)
+			}
+		}
 		// Generate seed from the custom mnemonic
 		seed := bip39.NewSeed(mnemonic, "")
 		masterKey, err := bip32.NewMasterKey(seed)
@@ -153,10 +197,14 @@ func (btc bitcoin) GenerateKeys() (*KeyPair, error) {
 		if err != nil {
 			return nil, err
 		}
+		origin, err = newKeyOrigin(masterKey, derivationPath)
+		if err != nil {
+			return nil, err
+		}
 
 		// Convert the child key to WIF
 		privKey, _ := btcec.PrivKeyFromBytes(childKey.Key)
-		privateKey, err = btcutil.NewWIF(privKey, btc.getParams(), true)
+		privateKey, err = btcutil.NewWIF(privKey, btc.getParams(), btc.compressed())
 		if err != nil {
 			return nil, err
 		}
@@ -184,10 +232,14 @@ func (btc bitcoin) GenerateKeys() (*KeyPair, error) {
 		if err != nil {
 			return nil, err
 		}
+		origin, err = newKeyOrigin(masterKey, btc.derivationPath)
+		if err != nil {
+			return nil, err
+		}
 
 		// Convert the child key to WIF
 		privKey, _ := btcec.PrivKeyFromBytes(childKey.Key)
-		privateKey, err = btcutil.NewWIF(privKey, btc.getParams(), true)
+		privateKey, err = btcutil.NewWIF(privKey, btc.getParams(), btc.compressed())
 		if err != nil {
 			return nil, err
 		}
@@ -199,8 +251,17 @@ func (btc bitcoin) GenerateKeys() (*KeyPair, error) {
 		}
 	}
 
-	// Derive the address from the private key
-	address, err := btc.getAddress(privateKey)
+	// Derive the address from the private key, committing to the
+	// tapleaf scripts if any were given
+	var address btcutil.Address
+	var details []detail
+	if len(tapLeafFlag) > 0 {
+		address, details, err = btc.getScriptPathAddress(privateKey)
+	} else if *numsFlag {
+		err = errors.New("the NUMS internal key requires at least one --tapleaf")
+	} else {
+		address, err = btc.getAddress(privateKey)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -209,15 +270,102 @@ func (btc bitcoin) GenerateKeys() (*KeyPair, error) {
 	k.network = btc.name
 	k.private = privateKey.String()
 	k.public = address.EncodeAddress()
+	k.keyFormat = keyFormat(privateKey)
+	k.details = details
+	if *numsFlag {
+		// The NUMS internal key has no known private key
+		k.private = "(no key path, NUMS internal key)"
+	} else if *bip38Flag {
+		passphrase, err := readBIP38Passphrase(true)
+		if err != nil {
+			return nil, err
+		}
+		if k.private, err = encryptBIP38(privateKey, passphrase); err != nil {
+			return nil, err
+		}
+	}
 	// Only include mnemonic and path if -a/--all is set
 	if *infoFlag || *infoLongFlag {
 		k.mnemonic = mnemonic
 		k.derivationPath = btc.derivationPath
+		k.origin = origin
 	}
 
 	return k, nil
 }
 
+// btcOrder is the order in which the bitcoin address types are listed.
+var btcOrder = []string{"legacy", "segwit", "native", "taproot"}
+
+// GenerateAllKeys returns the address of every bitcoin type for a single
+// private key. The key is taken from --custom_private, or generated when
+// no custom key is given. For an uncompressed WIF the legacy address of the
+// uncompressed public key is added as well.
+func GenerateAllKeys() ([]*KeyPair, error) {
+	var privateKey *btcutil.WIF
+	var err error
+
+	if customPrivate != "" {
+		privateKey, err = btcutil.DecodeWIF(customPrivate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode WIF private key: %v", err)
+		}
+		if !privateKey.IsForNet(&chaincfg.MainNetParams) {
+			return nil, fmt.Errorf("private key is not for mainnet")
+		}
+	} else {
+		privateKey, err = btcMap["legacy"].createPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return allKeyPairs(privateKey)
+}
+
+// allKeyPairs returns the address of every bitcoin type for privateKey
+func allKeyPairs(privateKey *btcutil.WIF) ([]*KeyPair, error) {
+	// SegWit addresses are listed for the compressed form of the key
+	compressed := privateKey
+	if !privateKey.CompressPubKey {
+		var err error
+		compressed, err = btcutil.NewWIF(privateKey.PrivKey, &chaincfg.MainNetParams, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var keyPairs []*KeyPair
+	for _, name := range btcOrder {
+		btc := btcMap[name]
+		address, err := btc.getAddress(compressed)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, &KeyPair{
+			network:   btc.name,
+			public:    address.EncodeAddress(),
+			private:   privateKey.String(),
+			keyFormat: keyFormat(compressed),
+		})
+	}
+
+	if !privateKey.CompressPubKey {
+		address, err := btcMap["legacy"].getAddress(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, &KeyPair{
+			network:   "bitcoin legacy (uncompressed)",
+			public:    address.EncodeAddress(),
+			private:   privateKey.String(),
+			keyFormat: keyFormat(privateKey),
+		})
+	}
+
+	return keyPairs, nil
+}
+
 func (btc bitcoin) deriveChildKeyFromMaster(masterKey *bip32.Key, path string) (*bip32.Key, error) {
 	// Split the derivation path into components
 	components := strings.Split(path, "/")
@@ -254,3 +402,81 @@ func (btc bitcoin) deriveChildKeyFromMaster(masterKey *bip32.Key, path string) (
 
 	return currentKey, nil
 }
+
+// btcAliases maps the network arguments to their btcMap entries
+var btcAliases = map[string]string{
+	"btc":     "legacy",
+	"legacy":  "legacy",
+	"bitcoin": "legacy",
+	"btcs":    "segwit",
+	"segwit":  "segwit",
+	"btcn":    "native",
+	"native":  "native",
+	"btct":    "taproot",
+	"taproot": "taproot",
+}
+
+// bitcoinByName returns the bitcoin address type for a network argument
+func bitcoinByName(name string) (bitcoin, bool) {
+	btc, ok := btcMap[btcAliases[strings.ToLower(name)]]
+	return btc, ok
+}
+
+// signInput signs input idx of tx, which spends pkScript with the key of
+// wif. The previous outputs of every input must be known to fetcher.
+// P2PKH, P2SH-P2WPKH, P2WPKH and P2TR key-path outputs are supported.
+func signInput(tx *wire.MsgTx, idx int, fetcher txscript.PrevOutputFetcher, wif *btcutil.WIF) error {
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[idx].PreviousOutPoint)
+	if prevOut == nil {
+		return fmt.Errorf("unknown previous output for input %d", idx)
+	}
+	pkScript, amount := prevOut.PkScript, prevOut.Value
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	txIn := tx.TxIn[idx]
+
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.PubKeyHashTy:
+		// The output may pay to either form of the public key
+		compress := bytes.Equal(pkScript[3:23], btcutil.Hash160(wif.PrivKey.PubKey().SerializeCompressed()))
+		sigScript, err := txscript.SignatureScript(tx, idx, pkScript, txscript.SigHashAll, wif.PrivKey, compress)
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript = sigScript
+	case txscript.WitnessV0PubKeyHashTy:
+		witness, err := txscript.WitnessSignature(tx, sigHashes, idx, amount, pkScript, txscript.SigHashAll, wif.PrivKey, true)
+		if err != nil {
+			return err
+		}
+		txIn.Witness = witness
+	case txscript.ScriptHashTy:
+		// Only P2SH-wrapped P2WPKH of this key can be signed
+		redeemScript, err := nestedWitnessScript(wif.PrivKey.PubKey())
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(pkScript[2:22], btcutil.Hash160(redeemScript)) {
+			return fmt.Errorf("input %d is not a P2SH-P2WPKH output of this key", idx)
+		}
+		witness, err := txscript.WitnessSignature(tx, sigHashes, idx, amount, redeemScript, txscript.SigHashAll, wif.PrivKey, true)
+		if err != nil {
+			return err
+		}
+		sigScript, err := txscript.NewScriptBuilder().AddData(redeemScript).Script()
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript = sigScript
+		txIn.Witness = witness
+	case txscript.WitnessV1TaprootTy:
+		witness, err := txscript.TaprootWitnessSignature(tx, sigHashes, idx, amount, pkScript, txscript.SigHashDefault, wif.PrivKey)
+		if err != nil {
+			return err
+		}
+		txIn.Witness = witness
+	default:
+		return fmt.Errorf("unsupported script type for input %d", idx)
+	}
+
+	return nil
+}
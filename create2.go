@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// safeSetupABI is the setup function of Safe singletons (v1.3.0 and later)
+const safeSetupABI = `[{"name":"setup","type":"function","inputs":[
+	{"name":"_owners","type":"address[]"},{"name":"_threshold","type":"uint256"},
+	{"name":"to","type":"address"},{"name":"data","type":"bytes"},
+	{"name":"fallbackHandler","type":"address"},{"name":"paymentToken","type":"address"},
+	{"name":"payment","type":"uint256"},{"name":"paymentReceiver","type":"address"}]}]`
+
+// hexArg decodes a hex argument, or the hex content of the file it names
+func hexArg(name, arg string) ([]byte, error) {
+	if data, err := os.ReadFile(arg); err == nil {
+		arg = string(data)
+	}
+	arg = strings.TrimPrefix(strings.TrimSpace(arg), "0x")
+	b, err := hexutil.Decode("0x" + arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return b, nil
+}
+
+// saltArg returns --salt, a decimal or 0x hex number, as 32 bytes
+func saltArg() ([32]byte, error) {
+	var salt [32]byte
+	n, ok := new(big.Int).SetString(*saltFlag, 0)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return salt, fmt.Errorf("invalid salt %q", *saltFlag)
+	}
+	n.FillBytes(salt[:])
+	return salt, nil
+}
+
+// smartAccountOwner returns --address, or the address of the Ethereum key
+func smartAccountOwner() (common.Address, error) {
+	if *addressFlag != "" {
+		return parseEthAddress(*addressFlag)
+	}
+	privateKey, err := ethPrivateKey()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%v, or give the owner with --address", err)
+	}
+	return crypto.PubkeyToAddress(privateKey.PublicKey), nil
+}
+
+// safeInitializer encodes the setup call of a Safe with owners, --threshold
+// and --fallback_handler
+func safeInitializer(owners []common.Address) ([]byte, error) {
+	threshold := *thresholdFlag
+	if threshold == 0 {
+		threshold = 1
+	}
+	if threshold < 1 || threshold > len(owners) {
+		return nil, fmt.Errorf("invalid threshold %d for %d owners", threshold, len(owners))
+	}
+	var fallbackHandler common.Address
+	if *fallbackFlag != "" {
+		var err error
+		if fallbackHandler, err = parseEthAddress(*fallbackFlag); err != nil {
+			return nil, err
+		}
+	}
+	setup, err := abi.JSON(strings.NewReader(safeSetupABI))
+	if err != nil {
+		return nil, err
+	}
+	return setup.Pack("setup", owners, big.NewInt(int64(threshold)), common.Address{}, []byte{},
+		fallbackHandler, common.Address{}, new(big.Int), common.Address{})
+}
+
+// SmartAccountAddress computes the CREATE2 address of a smart account
+// owned by the Ethereum key or --address, deployed by --factory. With
+// --singleton it is a Safe created by createProxyWithNonce, otherwise the
+// account of --init_code, where {owner} is replaced by the padded owner.
+func SmartAccountAddress() (*KeyPair, error) {
+	if *factoryFlag == "" {
+		return nil, errors.New("no factory given, use --factory")
+	}
+	factory, err := parseEthAddress(*factoryFlag)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := smartAccountOwner()
+	if err != nil {
+		return nil, err
+	}
+	salt, err := saltArg()
+	if err != nil {
+		return nil, err
+	}
+
+	k := &KeyPair{network: "ethereum"}
+	var initCode []byte
+	switch {
+	case *singletonFlag != "":
+		singleton, err := parseEthAddress(*singletonFlag)
+		if err != nil {
+			return nil, err
+		}
+		if *proxyCodeFlag == "" {
+			return nil, errors.New("no proxy creation code given, use --proxy_code")
+		}
+		proxyCode, err := hexArg("proxy creation code", *proxyCodeFlag)
+		if err != nil {
+			return nil, err
+		}
+		owners := []common.Address{owner}
+		for _, o := range ownerFlag {
+			address, err := parseEthAddress(o)
+			if err != nil {
+				return nil, err
+			}
+			owners = append(owners, address)
+		}
+		initializer, err := safeInitializer(owners)
+		if err != nil {
+			return nil, err
+		}
+
+		// salt = keccak256(keccak256(initializer) || saltNonce) and the
+		// proxy is deployed with the singleton as constructor argument
+		copy(salt[:], crypto.Keccak256(crypto.Keccak256(initializer), salt[:]))
+		initCode = append(proxyCode, common.LeftPadBytes(singleton[:], 32)...)
+		for _, o := range owners {
+			k.details = append(k.details, detail{"owner", checksumAddress(o, *chainIDFlag)})
+		}
+		k.details = append(k.details, detail{"initializer", hexutil.Encode(initializer)})
+	case *initCodeFlag != "":
+		template := *initCodeFlag
+		if data, err := os.ReadFile(template); err == nil {
+			template = string(data)
+		}
+		template = strings.ReplaceAll(template, "{owner}", common.Bytes2Hex(common.LeftPadBytes(owner[:], 32)))
+		if initCode, err = hexArg("init code", template); err != nil {
+			return nil, err
+		}
+		k.details = append(k.details, detail{"owner", checksumAddress(owner, *chainIDFlag)})
+	default:
+		return nil, errors.New("no account given, use --init_code or --singleton")
+	}
+
+	initCodeHash := crypto.Keccak256(initCode)
+	k.public = checksumAddress(crypto.CreateAddress2(factory, salt, initCodeHash), *chainIDFlag)
+	k.details = append(k.details,
+		detail{"factory", checksumAddress(factory, *chainIDFlag)},
+		detail{"salt", hexutil.Encode(salt[:])},
+		detail{"init hash", hexutil.Encode(initCodeHash)},
+	)
+	return k, nil
+}
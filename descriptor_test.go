@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+// TestDescriptorChecksum checks the BIP-380 test vectors
+func TestDescriptorChecksum(t *testing.T) {
+	if got := addDescriptorChecksum("raw(deadbeef)"); got != "raw(deadbeef)#89f8spxm" {
+		t.Errorf("addDescriptorChecksum(raw(deadbeef)) = %s, want raw(deadbeef)#89f8spxm", got)
+	}
+	// Characters outside the input charset have no checksum
+	if got := descriptorChecksum("raw(Ü)"); got != "" {
+		t.Errorf("descriptorChecksum(raw(Ü)) = %q, want no checksum", got)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Electrum v2 seed version prefixes of HMAC-SHA512("Seed version", seed)
+var electrumSeedPrefixes = []struct {
+	seedType string
+	prefix   string
+}{
+	{"standard", "01"},
+	{"segwit", "100"},
+	{"2fa", "101"},
+	{"2fa_segwit", "102"},
+}
+
+// SLIP-132 versions Electrum uses for segwit keys
+var (
+	zprvVersion = []byte{0x04, 0xb2, 0x43, 0x0c}
+	zpubVersion = []byte{0x04, 0xb2, 0x47, 0x46}
+)
+
+type electrum struct{}
+
+func (el electrum) Name() string {
+	return "Electrum"
+}
+
+// normalizeElectrumSeed normalizes a seed the way Electrum does: NFKD,
+// lower case, no accents, single spaces and no spaces between CJK
+// characters
+func normalizeElectrumSeed(seed string) string {
+	seed = strings.ToLower(norm.NFKD.String(seed))
+	var sb strings.Builder
+	for _, r := range seed {
+		if norm.NFKD.PropertiesString(string(r)).CCC() == 0 {
+			sb.WriteRune(r)
+		}
+	}
+	words := strings.Fields(sb.String())
+
+	isCJK := func(r rune) bool {
+		return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+	}
+	var result strings.Builder
+	for i, word := range words {
+		if i > 0 {
+			prev := []rune(words[i-1])
+			if !isCJK(prev[len(prev)-1]) || !isCJK([]rune(word)[0]) {
+				result.WriteByte(' ')
+			}
+		}
+		result.WriteString(word)
+	}
+	return result.String()
+}
+
+// electrumOldSeed returns the hex seed of an Electrum v1 seed, given as
+// 12 or 24 words of the old wordlist or as hex
+func electrumOldSeed(seed string) (string, bool) {
+	seed = normalizeElectrumSeed(seed)
+	if b, err := hex.DecodeString(seed); err == nil && (len(b) == 16 || len(b) == 32) {
+		return seed, true
+	}
+
+	words := strings.Fields(seed)
+	if len(words) != 12 && len(words) != 24 {
+		return "", false
+	}
+	index := make(map[string]int, len(electrumOldWords))
+	for i, w := range electrumOldWords {
+		index[w] = i
+	}
+	n := len(electrumOldWords)
+	var sb strings.Builder
+	for i := 0; i < len(words); i += 3 {
+		w1, ok1 := index[words[i]]
+		w2, ok2 := index[words[i+1]]
+		w3, ok3 := index[words[i+2]]
+		if !ok1 || !ok2 || !ok3 {
+			return "", false
+		}
+		x := w1 + n*(((w2-w1)%n+n)%n) + n*n*(((w3-w2)%n+n)%n)
+		fmt.Fprintf(&sb, "%08x", x)
+	}
+	return sb.String(), true
+}
+
+// electrumSeedType returns the type of an Electrum seed, or "" when seed
+// is not an Electrum seed
+func electrumSeedType(seed string) string {
+	if _, ok := electrumOldSeed(seed); ok {
+		return "old"
+	}
+	mac := hmac.New(sha512.New, []byte("Seed version"))
+	mac.Write([]byte(normalizeElectrumSeed(seed)))
+	version := hex.EncodeToString(mac.Sum(nil))
+	for _, p := range electrumSeedPrefixes {
+		if strings.HasPrefix(version, p.prefix) {
+			return p.seedType
+		}
+	}
+	return ""
+}
+
+// newElectrumSeed generates a 132-bit Electrum v2 seed of seedType. The
+// entropy is incremented until the seed has the version prefix and can
+// not be mistaken for a v1 or BIP-39 seed.
+func newElectrumSeed(seedType string) (string, error) {
+	wordlist := bip39.GetWordList()
+	n := big.NewInt(int64(len(wordlist)))
+
+	// At least 2^121 so the seed has 12 words
+	max := new(big.Int).Lsh(big.NewInt(1), 132)
+	min := new(big.Int).Lsh(big.NewInt(1), 121)
+	entropy := new(big.Int)
+	for entropy.Cmp(min) < 0 {
+		var err error
+		if entropy, err = rand.Int(rand.Reader, max); err != nil {
+			return "", err
+		}
+	}
+
+	for {
+		entropy.Add(entropy, big.NewInt(1))
+		var words []string
+		i, x := new(big.Int).Set(entropy), new(big.Int)
+		for i.Sign() > 0 {
+			i.DivMod(i, n, x)
+			words = append(words, wordlist[x.Int64()])
+		}
+		seed := strings.Join(words, " ")
+		if electrumSeedType(seed) == seedType && !bip39.IsMnemonicValid(seed) {
+			return seed, nil
+		}
+	}
+}
+
+// electrumWallet holds the keys of an Electrum seed
+type electrumWallet struct {
+	seed       string
+	seedType   string
+	master     *bip32.Key // bip32 wallets
+	account    *bip32.Key // bip32 wallets, m/ or m/0'
+	derivation string
+	oldSeed    string            // v1 wallets, hex seed
+	oldKey     *btcec.PrivateKey // v1 wallets, stretched master key
+}
+
+// newElectrumWallet derives the master keys of an Electrum v1 or v2 seed.
+// 2fa seeds need the TrustedCoin cosigner and are not supported.
+func newElectrumWallet(seed string) (*electrumWallet, error) {
+	w := &electrumWallet{seed: seed, seedType: electrumSeedType(seed)}
+	switch w.seedType {
+	case "old":
+		w.oldSeed, _ = electrumOldSeed(seed)
+
+		// Stretch the seed with 100000 rounds of SHA-256
+		stretched := []byte(w.oldSeed)
+		for i := 0; i < 100000; i++ {
+			hash := sha256.Sum256(append(stretched, w.oldSeed...))
+			stretched = hash[:]
+		}
+		w.oldKey, _ = btcec.PrivKeyFromBytes(stretched)
+		return w, nil
+	case "standard", "segwit":
+	case "2fa", "2fa_segwit":
+		return nil, fmt.Errorf("electrum %s seeds are not supported", w.seedType)
+	default:
+		return nil, errors.New("not an Electrum seed")
+	}
+
+	bip32Seed := pbkdf2.Key([]byte(normalizeElectrumSeed(seed)), []byte("electrum"), 2048, 64, sha512.New)
+	var err error
+	w.master, err = bip32.NewMasterKey(bip32Seed)
+	if err != nil {
+		return nil, err
+	}
+	w.account, w.derivation = w.master, "m"
+	if w.seedType == "segwit" {
+		w.derivation = "m/0'"
+		if w.account, err = w.master.NewChildKey(bip32.FirstHardenedChild); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// key returns the private key and address of receive (chain 0) or change
+// (chain 1) address index
+func (w *electrumWallet) key(chain, index uint32) (*btcutil.WIF, string, error) {
+	if w.oldKey != nil {
+		// Old wallets add sha256d("index:chain:" + mpk) to the master key
+		mpk := w.oldKey.PubKey().SerializeUncompressed()[1:]
+		data := append([]byte(fmt.Sprintf("%d:%d:", index, chain)), mpk...)
+		sequence := new(btcec.ModNScalar)
+		sequence.SetByteSlice(chainhash.DoubleHashB(data))
+		privKey := btcec.PrivKeyFromScalar(sequence.Add(&w.oldKey.Key))
+		wif, err := btcutil.NewWIF(privKey, &chaincfg.MainNetParams, false)
+		if err != nil {
+			return nil, "", err
+		}
+		address, err := btcMap["legacy"].getAddress(wif)
+		if err != nil {
+			return nil, "", err
+		}
+		return wif, address.EncodeAddress(), nil
+	}
+
+	child, err := w.account.NewChildKey(chain)
+	if err != nil {
+		return nil, "", err
+	}
+	if child, err = child.NewChildKey(index); err != nil {
+		return nil, "", err
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(child.Key)
+	wif, err := btcutil.NewWIF(privKey, &chaincfg.MainNetParams, true)
+	if err != nil {
+		return nil, "", err
+	}
+	btc := btcMap["legacy"]
+	if w.seedType == "segwit" {
+		btc = btcMap["native"]
+	}
+	address, err := btc.getAddress(wif)
+	if err != nil {
+		return nil, "", err
+	}
+	return wif, address.EncodeAddress(), nil
+}
+
+// masterKeys returns the extended keys of the account (xprv/xpub or
+// zprv/zpub for segwit), or the v1 master public key
+func (w *electrumWallet) masterKeys() (private, public string) {
+	if w.oldKey != nil {
+		return "", hex.EncodeToString(w.oldKey.PubKey().SerializeUncompressed()[1:])
+	}
+	priv, pub := *w.account, *w.account.PublicKey()
+	if w.seedType == "segwit" {
+		priv.Version, pub.Version = zprvVersion, zpubVersion
+	}
+	return priv.B58Serialize(), pub.B58Serialize()
+}
+
+// GenerateKeys derives the receive address --index of the Electrum seed
+// --custom_mnemonic, or of a new seed (--type legacy for a standard seed,
+// native for segwit)
+func (el electrum) GenerateKeys() (*KeyPair, error) {
+	seed := customMnemonic
+	if seed == "" {
+		var err error
+		switch strings.ToLower(*typeFlag) {
+		case "legacy", "btc":
+			seed, err = newElectrumSeed("standard")
+		case "native", "btcn":
+			seed, err = newElectrumSeed("segwit")
+		default:
+			err = fmt.Errorf("electrum seeds are legacy or native, not %q", *typeFlag)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if *indexFlag < 0 || *indexFlag >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("invalid index %d", *indexFlag)
+	}
+
+	w, err := newElectrumWallet(seed)
+	if err != nil {
+		return nil, err
+	}
+	wif, address, err := w.key(0, uint32(*indexFlag))
+	if err != nil {
+		return nil, err
+	}
+	_, change, err := w.key(1, uint32(*indexFlag))
+	if err != nil {
+		return nil, err
+	}
+	_, masterPublic := w.masterKeys()
+
+	k := &KeyPair{
+		network:   "electrum " + w.seedType,
+		public:    address,
+		private:   wif.String(),
+		keyFormat: keyFormat(wif),
+		details: []detail{
+			{"change", change},
+			{"master key", masterPublic},
+		},
+	}
+	if *infoFlag || *infoLongFlag {
+		k.mnemonic = seed
+		k.derivationPath = fmt.Sprintf("%s/0/%d", w.derivation, *indexFlag)
+		if w.oldKey != nil {
+			k.derivationPath = fmt.Sprintf("0/%d (electrum v1)", *indexFlag)
+		} else if k.origin, err = newKeyOrigin(w.master, k.derivationPath); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ExportElectrum writes an unencrypted Electrum wallet file with the
+// keystore of the Electrum seed --custom_mnemonic
+func ExportElectrum() error {
+	if customMnemonic == "" {
+		return errors.New("no seed given, use --custom_mnemonic")
+	}
+	w, err := newElectrumWallet(customMnemonic)
+	if err != nil {
+		return err
+	}
+
+	keystore := map[string]any{"seed": normalizeElectrumSeed(w.seed)}
+	if w.oldKey != nil {
+		_, mpk := w.masterKeys()
+		keystore["type"] = "old"
+		keystore["seed"] = w.oldSeed
+		keystore["mpk"] = mpk
+	} else {
+		xprv, xpub := w.masterKeys()
+		keystore["type"] = "bip32"
+		keystore["seed_type"] = w.seedType
+		keystore["xprv"] = xprv
+		keystore["xpub"] = xpub
+		keystore["derivation"] = w.derivation
+		keystore["root_fingerprint"] = hex.EncodeToString(btcutil.Hash160(w.master.PublicKey().Key)[:4])
+		keystore["pw_hash_version"] = 1
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"keystore":       keystore,
+		"seed_version":   18,
+		"use_encryption": false,
+		"wallet_type":    "standard",
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeExport(string(data) + "\n")
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+// TestElectrumSeeds checks the first receive and change addresses of the
+// seeds of Electrum's wallet tests
+func TestElectrumSeeds(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    string
+		receive string
+		change  string
+	}{
+		{"old", "powerful random nobody notice nothing important anyway look away hidden message over",
+			"1FJEEB8ihPMbzs2SkLmr37dHyRFzakqUmo", "1KRW8pH6HFHZh889VDq6fEKvmrsmApwNfe"},
+		{"standard", "cycle rocket west magnet parrot shuffle foot correct salt library feed song",
+			"1NNkttn1YvVGdqBW4PR6zvc3Zx3H5owKRf", "1KSezYMhAJMWqFbVFB2JshYg69UpmEXR4D"},
+		{"segwit", "bitter grass shiver impose acquire brush forget axis eager alone wine silver",
+			"bc1q3g5tmkmlvxryhh843v4dz026avatc0zzr6h3af", "bc1qdy94n2q5qcp0kg7v9yzwe6wvfkhnvyzje7nx2p"},
+	}
+	oldMnemonic, oldIndex := customMnemonic, *indexFlag
+	defer func() { customMnemonic, *indexFlag = oldMnemonic, oldIndex }()
+	*indexFlag = 0
+
+	for _, tt := range tests {
+		customMnemonic = tt.seed
+		k, err := electrum{}.GenerateKeys()
+		if err != nil {
+			t.Errorf("%s seed: %v", tt.name, err)
+			continue
+		}
+		if k.public != tt.receive {
+			t.Errorf("%s seed: receive %s, want %s", tt.name, k.public, tt.receive)
+		}
+		if change := detailValue(k, "change"); change != tt.change {
+			t.Errorf("%s seed: change %s, want %s", tt.name, change, tt.change)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/tyler-smith/go-bip32"
@@ -13,12 +16,35 @@ import (
 	"golang.org/x/crypto/sha3"
 )
 
-type ethereum struct{}
+// ethereum is Ethereum, or the EVM chain of chain
+type ethereum struct {
+	chain *evmChain
+}
 
 func (eth ethereum) Name() string {
+	if eth.chain != nil {
+		return eth.chain.title
+	}
 	return "Ethereum"
 }
 
+// coinType returns the BIP-44 coin type of the chain
+func (eth ethereum) coinType() int {
+	if eth.chain != nil {
+		return eth.chain.coinType
+	}
+	return 60
+}
+
+// checksumChainID returns the chain id of address checksums, --chain_id or
+// the chain id of chains using EIP-1191
+func (eth ethereum) checksumChainID() int64 {
+	if *chainIDFlag == 0 && eth.chain != nil && eth.chain.eip1191 {
+		return eth.chain.chainID
+	}
+	return *chainIDFlag
+}
+
 // parseDerivationPath parses a BIP-44 derivation path string into a slice of uint32 segments.
 func parseDerivationPath(path string) ([]uint32, error) {
 	segments := strings.Split(path, "/")
@@ -47,11 +73,86 @@ func parseDerivationPath(path string) ([]uint32, error) {
 	return result, nil
 }
 
+// ethPathPresets maps the wallets' path conventions to the path of coin
+// type %[1]d and account %[2]d
+var ethPathPresets = map[string]string{
+	"metamask":      "m/44'/%[1]d'/0'/0/%[2]d", // MetaMask, Trezor, Rabby
+	"ledger-live":   "m/44'/%[1]d'/%[2]d'/0/0", // Ledger Live
+	"ledger-legacy": "m/44'/%[1]d'/0'/%[2]d",   // Ledger Chrome app, MyEtherWallet
+}
+
+// ethPresetPath returns the path of account index in the wallet preset
+func (eth ethereum) presetPath(preset string, index int) (string, error) {
+	format, ok := ethPathPresets[preset]
+	if !ok {
+		return "", fmt.Errorf("unknown path preset %q, use metamask, ledger-live or ledger-legacy", preset)
+	}
+	if index < 0 || index >= 0x80000000 {
+		return "", fmt.Errorf("invalid index %d", index)
+	}
+	return fmt.Sprintf(format, eth.coinType(), index), nil
+}
+
+// ethDeriveKey derives the Ethereum key at path from masterKey
+func ethDeriveKey(masterKey *bip32.Key, path string) (*ecdsa.PrivateKey, error) {
+	segments, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	childKey := masterKey
+	for _, segment := range segments {
+		childKey, err = childKey.NewChildKey(segment)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(childKey.Key)
+}
+
+// ethPresetOrder is the order the presets are listed in
+var ethPresetOrder = []string{"metamask", "ledger-live", "ledger-legacy"}
+
+// accounts lists the address and path of the first --accounts accounts of
+// mnemonic with --path_preset, or with every preset so that funds are found
+// whichever wallet created them. Paths shared by presets are listed once.
+func (eth ethereum) accounts(mnemonic string) ([]detail, error) {
+	presets := ethPresetOrder
+	if *pathPresetFlag != "" {
+		presets = []string{*pathPresetFlag}
+	}
+	masterKey, err := bip32.NewMasterKey(bip39.NewSeed(mnemonic, ""))
+	if err != nil {
+		return nil, err
+	}
+	var details []detail
+	listed := map[string]bool{}
+	for _, preset := range presets {
+		for i := 0; i < *accountsFlag; i++ {
+			path, err := eth.presetPath(preset, i)
+			if err != nil {
+				return nil, err
+			}
+			if listed[path] {
+				continue
+			}
+			listed[path] = true
+			privateKey, err := ethDeriveKey(masterKey, path)
+			if err != nil {
+				return nil, err
+			}
+			address := checksumAddress(crypto.PubkeyToAddress(privateKey.PublicKey), eth.checksumChainID())
+			details = append(details, detail{fmt.Sprintf("%s %d", preset, i), address + " " + path})
+		}
+	}
+	return details, nil
+}
+
 // GenerateKeys for eth
 func (eth ethereum) GenerateKeys() (*KeyPair, error) {
 	var privateKey *ecdsa.PrivateKey
 	var mnemonic string
 	var derivationPath string
+	var origin *keyOrigin
 	var err error
 
 	// Check for custom private key first
@@ -77,7 +178,7 @@ func (eth ethereum) GenerateKeys() (*KeyPair, error) {
 			derivationPath = "(cannot derive path from private key)"
 		}
 
-	} else if *infoFlag || *infoLongFlag || customMnemonic != "" {
+	} else if *infoFlag || *infoLongFlag || customMnemonic != "" || *pathPresetFlag != "" || *accountsFlag > 0 {
 		// If the mnemonic flag is set, generate mnemonic and derive the key pair
 		if customMnemonic != "" {
 			mnemonic = customMnemonic
@@ -100,32 +201,34 @@ func (eth ethereum) GenerateKeys() (*KeyPair, error) {
 			return nil, err
 		}
 
-		// Default BIP-44 path for Ethereum: m/44'/60'/0'/0/0
-		defaultPath := []uint32{0x80000000 + 44, 0x80000000 + 60, 0x80000000 + 0, 0, 0}
-		derivationPath = "m/44'/60'/0'/0/0"
+		// Default BIP-44 path for Ethereum: m/44'/60'/0'/0/0 with the coin
+		// type of the chain, or the --path_preset path of account --index
+		derivationPath = fmt.Sprintf("m/44'/%d'/0'/0/0", eth.coinType())
+		if *pathPresetFlag != "" {
+			if derivationPath, err = eth.presetPath(*pathPresetFlag, *indexFlag); err != nil {
+				return nil, err
+			}
+		}
 
 		// If a custom derivation path is provided, use it
 		if customPath != "" {
-			defaultPath, err = parseDerivationPath(customPath)
-			if err != nil {
-				return nil, err
+			if *pathPresetFlag != "" {
+				return nil, errors.New("use either --custom_path or --path_preset")
 			}
 			derivationPath = customPath
 		}
 
-		// Derive the key using the specified path
-		childKey := masterKey
-		for _, segment := range defaultPath {
-			childKey, err = childKey.NewChildKey(segment)
+		privateKey, err = ethDeriveKey(masterKey, derivationPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if *infoFlag || *infoLongFlag {
+			origin, err = newKeyOrigin(masterKey, derivationPath)
 			if err != nil {
 				return nil, err
 			}
 		}
-
-		privateKey, err = crypto.ToECDSA(childKey.Key)
-		if err != nil {
-			return nil, err
-		}
 	} else {
 		// Generate a new random key if no custom options
 		privateKey, err = crypto.GenerateKey()
@@ -147,11 +250,113 @@ func (eth ethereum) GenerateKeys() (*KeyPair, error) {
 	hash := sha3.NewLegacyKeccak256()
 	hash.Write(publicKeyBytes[1:])
 
-	return &KeyPair{
+	k := &KeyPair{
 		network:        "ethereum",
 		private:        hexutil.Encode(privateKeyBytes)[2:],
-		public:         hexutil.Encode(hash.Sum(nil)[12:]),
+		public:         checksumAddress(common.BytesToAddress(hash.Sum(nil)[12:]), eth.checksumChainID()),
 		mnemonic:       mnemonic,
 		derivationPath: derivationPath,
-	}, nil
+		origin:         origin,
+	}
+	if eth.chain != nil {
+		k.network = eth.chain.name
+		k.details = append(k.details,
+			detail{"chain id", fmt.Sprint(eth.chain.chainID)},
+			detail{"explorer", eth.chain.explorer + k.public},
+		)
+	}
+
+	// List the addresses of the first --accounts accounts of the preset
+	if *accountsFlag > 0 {
+		if customPrivate != "" {
+			return nil, errors.New("listing accounts needs a mnemonic, not --custom_private")
+		}
+		if customPath != "" {
+			return nil, errors.New("use either --custom_path or --accounts")
+		}
+		accounts, err := eth.accounts(mnemonic)
+		if err != nil {
+			return nil, err
+		}
+		k.details = append(k.details, accounts...)
+	}
+
+	// With --keystore the private key is only written encrypted
+	if *keystoreFlag != "" {
+		path, err := writeKeystore(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		k.private = ""
+		k.details = append(k.details, detail{"keystore", path})
+	}
+	return k, nil
+}
+
+// checksumAddress returns the mixed-case checksum encoding of address,
+// EIP-55 or EIP-1191 when chainID is not 0. EIP-1191 hashes the chain id
+// with the address and is only used by the chains that adopted it (RSK).
+func checksumAddress(address common.Address, chainID int64) string {
+	lower := hex.EncodeToString(address[:])
+	input := lower
+	if chainID != 0 {
+		input = strconv.FormatInt(chainID, 10) + "0x" + lower
+	}
+	hash := crypto.Keccak256([]byte(input))
+
+	result := []byte(lower)
+	for i, c := range result {
+		// Upper case the letters whose hash nibble is 8 or more
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			result[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(result)
+}
+
+// parseEthAddress parses a hex Ethereum address. Mixed-case addresses
+// must carry a valid EIP-55 checksum, or EIP-1191 with --chain_id.
+func parseEthAddress(s string) (common.Address, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		s = "0x" + s
+	}
+	if len(s) != 42 {
+		return common.Address{}, fmt.Errorf("invalid ethereum address %q", s)
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid ethereum address %q", s)
+	}
+	address := common.BytesToAddress(b)
+
+	hexPart := s[2:]
+	if hexPart == strings.ToLower(hexPart) || hexPart == strings.ToUpper(hexPart) {
+		return address, nil
+	}
+	if s[2:] != checksumAddress(address, *chainIDFlag)[2:] {
+		return common.Address{}, fmt.Errorf("invalid checksum for ethereum address %s, expected %s", s, checksumAddress(address, *chainIDFlag))
+	}
+	return address, nil
+}
+
+// EthAddress validates --address and returns its checksum encodings
+func EthAddress() (*KeyPair, error) {
+	address, err := parseEthAddress(*addressFlag)
+	if err != nil {
+		return nil, err
+	}
+	k := &KeyPair{
+		network: "ethereum",
+		public:  checksumAddress(address, *chainIDFlag),
+		details: []detail{{"eip-55", checksumAddress(address, 0)}},
+	}
+	if *chainIDFlag != 0 {
+		k.details = append(k.details, detail{"eip-1191", checksumAddress(address, *chainIDFlag)})
+	}
+	k.details = append(k.details, detail{"checksum", "valid"})
+	return k, nil
 }
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/text/unicode/norm"
+)
+
+// EIP-2334 validator paths of validator %d and deposit constants
+const (
+	eth2SigningPath    = "m/12381/3600/%d/0/0"
+	eth2WithdrawalPath = "m/12381/3600/%d/0"
+	eth2DepositAmount  = 32000000000 // gwei
+	eth2BLSDST         = "BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_"
+)
+
+// eth2DepositCLIVersion is the staking-deposit-cli release whose
+// deposit_data.json format is written, Launchpad-style tools check it
+const eth2DepositCLIVersion = "2.7.0"
+
+// eth2DomainDeposit is the signature domain type of deposits
+var eth2DomainDeposit = []byte{0x03, 0x00, 0x00, 0x00}
+
+// eth2ForkVersions are the genesis fork versions of the public networks
+var eth2ForkVersions = map[string]string{
+	"mainnet": "00000000",
+	"sepolia": "90000069",
+	"holesky": "01017000",
+	"hoodi":   "10000910",
+}
+
+type eth2 struct{}
+
+func (e eth2) Name() string {
+	return "Ethereum Validator"
+}
+
+// eth2HKDFModR derives a BLS secret key from ikm as the EIP-2333 HKDF_mod_r
+func eth2HKDFModR(ikm []byte) *big.Int {
+	salt := []byte("BLS-SIG-KEYGEN-SALT-")
+	sk := new(big.Int)
+	for sk.Sign() == 0 {
+		hash := sha256.Sum256(salt)
+		salt = hash[:]
+		prk := hkdf.Extract(sha256.New, append(append([]byte{}, ikm...), 0), salt)
+		okm := make([]byte, 48)
+		if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte{0, 48}), okm); err != nil {
+			panic(err) // 48 bytes are always available
+		}
+		sk.SetBytes(okm).Mod(sk, fr.Modulus())
+	}
+	return sk
+}
+
+// eth2LamportPK returns the compressed EIP-2333 lamport public key of a
+// parent key and child index
+func eth2LamportPK(parent *big.Int, index uint32) []byte {
+	salt := binary.BigEndian.AppendUint32(nil, index)
+	ikm := parent.FillBytes(make([]byte, 32))
+	notIKM := make([]byte, 32)
+	for i, b := range ikm {
+		notIKM[i] = ^b
+	}
+
+	lamportPK := sha256.New()
+	for _, secret := range [][]byte{ikm, notIKM} {
+		prk := hkdf.Extract(sha256.New, secret, salt)
+		okm := make([]byte, 32*255)
+		if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, nil), okm); err != nil {
+			panic(err) // 8160 bytes are within the HKDF limit
+		}
+		for i := 0; i < 255; i++ {
+			chunk := sha256.Sum256(okm[i*32 : i*32+32])
+			lamportPK.Write(chunk[:])
+		}
+	}
+	return lamportPK.Sum(nil)
+}
+
+// eth2DeriveKey derives the EIP-2333 key at path from seed
+func eth2DeriveKey(seed []byte, path string) (*big.Int, error) {
+	segments := strings.Split(path, "/")
+	if len(segments) < 1 || segments[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path: %s", path)
+	}
+	sk := eth2HKDFModR(seed)
+	for _, segment := range segments[1:] {
+		var index uint32
+		if _, err := fmt.Sscan(segment, &index); err != nil || fmt.Sprint(index) != segment {
+			return nil, fmt.Errorf("invalid segment in derivation path: %s", segment)
+		}
+		sk = eth2HKDFModR(eth2LamportPK(sk, index))
+	}
+	return sk, nil
+}
+
+// eth2PubKey returns the compressed G1 public key of sk
+func eth2PubKey(sk *big.Int) []byte {
+	var pk bls12381.G1Affine
+	pk.ScalarMultiplicationBase(sk)
+	b := pk.Bytes()
+	return b[:]
+}
+
+// eth2Sign signs message with sk in the proof of possession scheme of the
+// consensus layer
+func eth2Sign(sk *big.Int, message []byte) ([]byte, error) {
+	h, err := bls12381.HashToG2(message, []byte(eth2BLSDST))
+	if err != nil {
+		return nil, err
+	}
+	var sig bls12381.G2Affine
+	sig.ScalarMultiplication(&h, sk)
+	b := sig.Bytes()
+	return b[:], nil
+}
+
+// sszRoot merkleizes 32-byte chunks, padded to a power of two
+func sszRoot(chunks ...[]byte) []byte {
+	n := 1
+	for n < len(chunks) {
+		n *= 2
+	}
+	layer := make([][]byte, n)
+	for i := range layer {
+		layer[i] = make([]byte, 32)
+		if i < len(chunks) {
+			copy(layer[i], chunks[i])
+		}
+	}
+	for len(layer) > 1 {
+		next := make([][]byte, len(layer)/2)
+		for i := range next {
+			hash := sha256.Sum256(append(append([]byte{}, layer[2*i]...), layer[2*i+1]...))
+			next[i] = hash[:]
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+// sszBytesRoot is the hash tree root of a fixed size byte vector
+func sszBytesRoot(b []byte) []byte {
+	var chunks [][]byte
+	for i := 0; i < len(b); i += 32 {
+		chunks = append(chunks, b[i:min(i+32, len(b))])
+	}
+	return sszRoot(chunks...)
+}
+
+// sszUint64 is the chunk of a uint64
+func sszUint64(v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, v)
+}
+
+// eth2ForkVersion returns --fork_version, a network name or 4 hex bytes
+func eth2ForkVersion() (version []byte, network string, err error) {
+	network = *forkVersionFlag
+	value, ok := eth2ForkVersions[network]
+	if !ok {
+		value, network = strings.TrimPrefix(network, "0x"), ""
+	}
+	version, err = hex.DecodeString(value)
+	if err != nil || len(version) != 4 {
+		return nil, "", fmt.Errorf("invalid fork version %q, use 4 bytes of hex or mainnet, sepolia, holesky or hoodi", *forkVersionFlag)
+	}
+	return version, network, nil
+}
+
+// eth2DepositData is one entry of deposit_data.json as the deposit CLI
+// writes it
+type eth2DepositData struct {
+	PubKey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Amount                uint64 `json:"amount"`
+	Signature             string `json:"signature"`
+	DepositMessageRoot    string `json:"deposit_message_root"`
+	DepositDataRoot       string `json:"deposit_data_root"`
+	ForkVersion           string `json:"fork_version"`
+	NetworkName           string `json:"network_name,omitempty"`
+	DepositCLIVersion     string `json:"deposit_cli_version"`
+}
+
+// eth2Deposit signs the deposit of the validator key sk
+func eth2Deposit(sk *big.Int, withdrawalCredentials []byte) (*eth2DepositData, error) {
+	forkVersion, network, err := eth2ForkVersion()
+	if err != nil {
+		return nil, err
+	}
+	pubKey := eth2PubKey(sk)
+	messageRoot := sszRoot(sszBytesRoot(pubKey), withdrawalCredentials, sszUint64(eth2DepositAmount))
+
+	// Deposits are signed for the genesis fork with a zero genesis
+	// validators root
+	forkDataRoot := sszRoot(forkVersion, make([]byte, 32))
+	domain := append(append([]byte{}, eth2DomainDeposit...), forkDataRoot[:28]...)
+	signature, err := eth2Sign(sk, sszRoot(messageRoot, domain))
+	if err != nil {
+		return nil, err
+	}
+
+	dataRoot := sszRoot(sszBytesRoot(pubKey), withdrawalCredentials, sszUint64(eth2DepositAmount), sszBytesRoot(signature))
+	return &eth2DepositData{
+		PubKey:                hex.EncodeToString(pubKey),
+		WithdrawalCredentials: hex.EncodeToString(withdrawalCredentials),
+		Amount:                eth2DepositAmount,
+		Signature:             hex.EncodeToString(signature),
+		DepositMessageRoot:    hex.EncodeToString(messageRoot),
+		DepositDataRoot:       hex.EncodeToString(dataRoot),
+		ForkVersion:           hex.EncodeToString(forkVersion),
+		NetworkName:           network,
+		DepositCLIVersion:     eth2DepositCLIVersion,
+	}, nil
+}
+
+// eth2WithdrawalCredentials returns 0x01 credentials of --withdrawal_address,
+// or 0x00 credentials of the BLS withdrawal key
+func eth2WithdrawalCredentials(withdrawalKey *big.Int) ([]byte, error) {
+	credentials := make([]byte, 32)
+	if *withdrawalAddrFlag != "" {
+		address, err := parseEthAddress(*withdrawalAddrFlag)
+		if err != nil {
+			return nil, err
+		}
+		credentials[0] = 0x01
+		copy(credentials[12:], address[:])
+		return credentials, nil
+	}
+	hash := sha256.Sum256(eth2PubKey(withdrawalKey))
+	copy(credentials[1:], hash[1:])
+	return credentials, nil
+}
+
+// eth2Keystore is an EIP-2335 BLS keystore
+type eth2Keystore struct {
+	Crypto struct {
+		KDF struct {
+			Function string         `json:"function"`
+			Params   keystoreParams `json:"params"`
+			Message  string         `json:"message"`
+		} `json:"kdf"`
+		Checksum struct {
+			Function string   `json:"function"`
+			Params   struct{} `json:"params"`
+			Message  string   `json:"message"`
+		} `json:"checksum"`
+		Cipher struct {
+			Function string `json:"function"`
+			Params   struct {
+				IV string `json:"iv"`
+			} `json:"params"`
+			Message string `json:"message"`
+		} `json:"cipher"`
+	} `json:"crypto"`
+	Description string `json:"description"`
+	PubKey      string `json:"pubkey"`
+	Path        string `json:"path"`
+	UUID        string `json:"uuid"`
+	Version     int    `json:"version"`
+}
+
+// eth2Password processes a keystore password as EIP-2335 requires, NFKD
+// normalized without control codes
+func eth2Password(password string) []byte {
+	return []byte(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, norm.NFKD.String(password)))
+}
+
+// encryptEth2Keystore encrypts the validator key sk at path into an
+// EIP-2335 keystore with the --kdf and --kdf_cost parameters
+func encryptEth2Keystore(sk *big.Int, path, password string) ([]byte, error) {
+	var k eth2Keystore
+	params, err := newKeystoreParams()
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	derivedKey, err := params.deriveKey(*kdfFlag, eth2Password(password))
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := aesCTR(derivedKey[:16], iv, sk.FillBytes(make([]byte, 32)))
+	if err != nil {
+		return nil, err
+	}
+	checksum := sha256.Sum256(append(append([]byte{}, derivedKey[16:32]...), cipherText...))
+
+	k.Crypto.KDF.Function = *kdfFlag
+	k.Crypto.KDF.Params = params
+	k.Crypto.Checksum.Function = "sha256"
+	k.Crypto.Checksum.Message = hex.EncodeToString(checksum[:])
+	k.Crypto.Cipher.Function = "aes-128-ctr"
+	k.Crypto.Cipher.Params.IV = hex.EncodeToString(iv)
+	k.Crypto.Cipher.Message = hex.EncodeToString(cipherText)
+	k.PubKey = hex.EncodeToString(eth2PubKey(sk))
+	k.Path = path
+	k.Version = 4
+	if k.UUID, err = newUUID(); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(k, "", "  ")
+}
+
+// writeEth2Files writes the keystores of the validator keys and their
+// deposit data into the --keystore directory
+func writeEth2Files(keys []*big.Int, paths []string, deposits []*eth2DepositData) ([]detail, error) {
+	password, err := readPassphrase("New keystore password: ", true)
+	if err != nil {
+		return nil, err
+	}
+	if len(eth2Password(password)) < 8 {
+		return nil, errors.New("the keystore password must have at least 8 characters")
+	}
+	if err := os.MkdirAll(*keystoreFlag, 0700); err != nil {
+		return nil, err
+	}
+
+	var details []detail
+	timestamp := time.Now().Unix()
+	for i, sk := range keys {
+		data, err := encryptEth2Keystore(sk, paths[i], password)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("keystore-%s-%d.json", strings.ReplaceAll(paths[i], "/", "_"), timestamp)
+		path := filepath.Join(*keystoreFlag, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return nil, err
+		}
+		details = append(details, detail{"keystore", path})
+	}
+
+	data, err := json.Marshal(deposits)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(*keystoreFlag, fmt.Sprintf("deposit_data-%d.json", timestamp))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return append(details, detail{"deposit data", path}), nil
+}
+
+// GenerateKeys derives the signing and withdrawal keys of --validators
+// validators from --index of --custom_mnemonic, or of a new mnemonic. With
+// --keystore their keystores and deposit data are written.
+func (e eth2) GenerateKeys() (*KeyPair, error) {
+	// Validator keys are only derived from a mnemonic, on the EIP-2334 paths
+	if customPrivate != "" {
+		return nil, errors.New("validator keys need a mnemonic, not --custom_private")
+	}
+	if customPath != "" {
+		return nil, errors.New("validator keys use the EIP-2334 paths, use --index instead of --custom_path")
+	}
+	mnemonic := customMnemonic
+	if mnemonic == "" {
+		entropy, err := bip39.NewEntropy(256)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			return nil, err
+		}
+	} else if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("invalid mnemonic phrase")
+	}
+	if *indexFlag < 0 || *validatorsFlag < 1 || *indexFlag+*validatorsFlag > 1<<32 {
+		return nil, errors.New("invalid validator index or count")
+	}
+	seed := bip39.NewSeed(mnemonic, "")
+
+	var keys []*big.Int
+	var paths []string
+	var deposits []*eth2DepositData
+	k := &KeyPair{network: "eth2", mnemonic: mnemonic}
+	for i := *indexFlag; i < *indexFlag+*validatorsFlag; i++ {
+		path := fmt.Sprintf(eth2SigningPath, i)
+		sk, err := eth2DeriveKey(seed, path)
+		if err != nil {
+			return nil, err
+		}
+		withdrawalKey, err := eth2DeriveKey(seed, fmt.Sprintf(eth2WithdrawalPath, i))
+		if err != nil {
+			return nil, err
+		}
+		credentials, err := eth2WithdrawalCredentials(withdrawalKey)
+		if err != nil {
+			return nil, err
+		}
+		deposit, err := eth2Deposit(sk, credentials)
+		if err != nil {
+			return nil, err
+		}
+		keys, paths, deposits = append(keys, sk), append(paths, path), append(deposits, deposit)
+
+		// The first validator is the key pair, the others are listed
+		if i == *indexFlag {
+			k.public = "0x" + deposit.PubKey
+			k.private = hex.EncodeToString(sk.FillBytes(make([]byte, 32)))
+			k.derivationPath = path
+			k.details = append(k.details,
+				detail{"withdrawal", "0x" + hex.EncodeToString(eth2PubKey(withdrawalKey))},
+				detail{"credentials", "0x" + deposit.WithdrawalCredentials},
+			)
+			continue
+		}
+		k.details = append(k.details, detail{fmt.Sprintf("validator %d", i), "0x" + deposit.PubKey})
+	}
+
+	// With --keystore the keys are only written encrypted
+	if *keystoreFlag != "" {
+		details, err := writeEth2Files(keys, paths, deposits)
+		if err != nil {
+			return nil, err
+		}
+		k.private = ""
+		k.details = append(k.details, details...)
+	}
+	return k, nil
+}
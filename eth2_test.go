@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// TestEth2DeriveKey checks the EIP-2333 test cases
+func TestEth2DeriveKey(t *testing.T) {
+	tests := []struct {
+		seed   string
+		master string
+		index  uint32
+		child  string
+	}{
+		{
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+			"6083874454709270928345386274498605044986640685124978867557563392430687146096",
+			0,
+			"20397789859736650942317412262472558107875392172444076792671091975210932703118",
+		},
+		{
+			"3141592653589793238462643383279502884197169399375105820974944592",
+			"29757020647961307431480504535336562678282505419141012933316116377660817309383",
+			3141592653,
+			"25457201688850691947727629385191704516744796114925897962676248250929345014287",
+		},
+		{
+			"0099FF991111002299DD7744EE3355BBDD8844115566CC55663355668888CC00",
+			"27580842291869792442942448775674722299803720648445448686099262467207037398656",
+			4294967295,
+			"29358610794459428860402234341874281240803786294062035874021252734817515685787",
+		},
+		{
+			"d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+			"19022158461524446591288038168518313374041767046816487870552872741050760015818",
+			42,
+			"31372231650479070279774297061823572166496564838472787488249775572789064611981",
+		},
+	}
+	for _, tt := range tests {
+		seed, err := hex.DecodeString(tt.seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		master, err := eth2DeriveKey(seed, "m")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if master.String() != tt.master {
+			t.Errorf("seed %s: master %s, want %s", tt.seed, master, tt.master)
+		}
+		child, err := eth2DeriveKey(seed, fmt.Sprintf("m/%d", tt.index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if child.String() != tt.child {
+			t.Errorf("seed %s: child %d %s, want %s", tt.seed, tt.index, child, tt.child)
+		}
+	}
+}
+
+// TestEth2Sign checks a BLS signing vector of the consensus spec tests
+func TestEth2Sign(t *testing.T) {
+	sk, _ := new(big.Int).SetString("263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3", 16)
+	wantPubKey := "a491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a"
+	wantSignature := "b6ed936746e01f8ecf281f020953fbf1f01debd5657c4a383940b020b26507f6076334f91e2366c96e9ab279fb5158090352ea1c5b0c9274504f4f0e7053af24802e51e4568d164fe986834f41e55c8e850ce1f98458c0cfc9ab380b55285a55"
+
+	if pubKey := hex.EncodeToString(eth2PubKey(sk)); pubKey != wantPubKey {
+		t.Errorf("public key %s, want %s", pubKey, wantPubKey)
+	}
+	signature, err := eth2Sign(sk, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(signature) != wantSignature {
+		t.Errorf("signature %x, want %s", signature, wantSignature)
+	}
+}
+
+// TestEth2DepositDomain checks the mainnet deposit domain
+func TestEth2DepositDomain(t *testing.T) {
+	forkDataRoot := sszRoot(make([]byte, 4), make([]byte, 32))
+	domain := append(append([]byte{}, eth2DomainDeposit...), forkDataRoot[:28]...)
+	want := "03000000f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9"
+	if hex.EncodeToString(domain) != want {
+		t.Errorf("deposit domain %x, want %s", domain, want)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestChecksumAddress checks the EIP-55 and EIP-1191 (RSK mainnet 30 and
+// testnet 31) test vectors
+func TestChecksumAddress(t *testing.T) {
+	tests := []struct {
+		chainID   int64
+		addresses []string
+	}{
+		{0, []string{
+			"0x52908400098527886E0F7030069857D2E4169EE7",
+			"0x8617E340B3D01FA5F11F306F4090FD50E238070D",
+			"0xde709f2102306220921060314715629080e2fb77",
+			"0x27b1fdb04752bbc536007a920d24acb045561c26",
+			"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+			"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+			"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+		}},
+		{30, []string{
+			"0x5aaEB6053f3e94c9b9a09f33669435E7ef1bEAeD",
+			"0xFb6916095cA1Df60bb79ce92cE3EA74c37c5d359",
+			"0xDBF03B407c01E7CD3cBea99509D93F8Dddc8C6FB",
+			"0xD1220A0Cf47c7B9BE7a2e6ba89F429762E7B9adB",
+		}},
+		{31, []string{
+			"0x5aAeb6053F3e94c9b9A09F33669435E7EF1BEaEd",
+			"0xFb6916095CA1dF60bb79CE92ce3Ea74C37c5D359",
+			"0xdbF03B407C01E7cd3cbEa99509D93f8dDDc8C6fB",
+		}},
+	}
+	for _, tt := range tests {
+		for _, want := range tt.addresses {
+			if got := checksumAddress(common.HexToAddress(want), tt.chainID); got != want {
+				t.Errorf("chain %d: checksum %s, want %s", tt.chainID, got, want)
+			}
+		}
+	}
+}
+
+// TestParseEthAddress checks that a wrong checksum is rejected and that
+// all lower or upper case addresses carry none
+func TestParseEthAddress(t *testing.T) {
+	oldChainID := *chainIDFlag
+	defer func() { *chainIDFlag = oldChainID }()
+	*chainIDFlag = 0
+
+	valid := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	for _, s := range []string{valid, strings.ToLower(valid), "0x" + strings.ToUpper(valid[2:])} {
+		if _, err := parseEthAddress(s); err != nil {
+			t.Errorf("parseEthAddress(%s): %v", s, err)
+		}
+	}
+	if _, err := parseEthAddress("0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"); err == nil {
+		t.Error("accepted an address with a wrong checksum")
+	}
+	// The EIP-55 encoding is not a valid RSK checksum
+	*chainIDFlag = 30
+	if _, err := parseEthAddress(valid); err == nil {
+		t.Error("accepted an EIP-55 checksum as EIP-1191")
+	}
+}
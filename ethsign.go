@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ethPrivateKey returns the Ethereum key of --custom_private or
+// --custom_mnemonic and --custom_path
+func ethPrivateKey() (*ecdsa.PrivateKey, error) {
+	if customPrivate == "" && customMnemonic == "" {
+		return nil, errors.New("no key given, use --custom_private or --custom_mnemonic")
+	}
+	k, err := ethereum{}.GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	if k.private == "" {
+		return nil, errors.New("signing does not support --keystore")
+	}
+	return crypto.HexToECDSA(k.private)
+}
+
+// ethMessageHash returns the EIP-191 personal_sign hash of --message, or
+// the EIP-712 hash of the --typed_data file. Messages starting with 0x are
+// signed as bytes, like personal_sign does. With --format hash the message
+// is the hash, for recovering signers only.
+func ethMessageHash() (hash []byte, details []detail, err error) {
+	if *typedDataFlag != "" {
+		data, err := os.ReadFile(*typedDataFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+		var typedData apitypes.TypedData
+		if err := json.Unmarshal(data, &typedData); err != nil {
+			return nil, nil, fmt.Errorf("invalid typed data: %v", err)
+		}
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid typed data: %v", err)
+		}
+		return hash, []detail{
+			{"primary type", typedData.PrimaryType},
+			{"hash", hexutil.Encode(hash)},
+		}, nil
+	}
+
+	// A 32-byte hash is signed as is
+	if *formatFlag == "hash" {
+		hash, err := hexutil.Decode(*messageFlag)
+		if err != nil || len(hash) != 32 {
+			return nil, nil, errors.New("invalid hash, use 32 bytes of 0x hex")
+		}
+		return hash, []detail{{"hash", hexutil.Encode(hash)}}, nil
+	}
+
+	message := []byte(*messageFlag)
+	if strings.HasPrefix(*messageFlag, "0x") {
+		if message, err = hexutil.Decode(*messageFlag); err != nil {
+			return nil, nil, fmt.Errorf("invalid hex message: %v", err)
+		}
+	}
+	hash = accounts.TextHash(message)
+	return hash, []detail{
+		{"message", *messageFlag},
+		{"hash", hexutil.Encode(hash)},
+	}, nil
+}
+
+// EthSignMessage signs --message (EIP-191) or --typed_data (EIP-712) with
+// the Ethereum key. The signature is r || s || v with v 27 or 28.
+func EthSignMessage() (*KeyPair, error) {
+	// A raw hash could be a transaction or permit digest, only recovery
+	// takes one
+	if *formatFlag == "hash" {
+		return nil, errors.New("signing a raw hash is not supported, use --message or --typed_data")
+	}
+	privateKey, err := ethPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	hash, details, err := ethMessageHash()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := crypto.Sign(hash, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return &KeyPair{
+		network: "ethereum",
+		public:  checksumAddress(crypto.PubkeyToAddress(privateKey.PublicKey), *chainIDFlag),
+		details: append(details, detail{"signature", hexutil.Encode(signature)}),
+	}, nil
+}
+
+// ethRecover recovers the public key that signed --message or --typed_data
+// with --signature
+func ethRecover() (*ecdsa.PublicKey, []detail, error) {
+	signature, err := hexutil.Decode(*signatureFlag)
+	if err != nil || len(signature) != crypto.SignatureLength {
+		return nil, nil, errors.New("invalid signature, use 65 bytes of hex r || s || v")
+	}
+	// Both v encodings, 0/1 and 27/28, are in use
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	if signature[crypto.RecoveryIDOffset] > 1 {
+		return nil, nil, fmt.Errorf("invalid signature recovery id %d", signature[crypto.RecoveryIDOffset])
+	}
+	hash, details, err := ethMessageHash()
+	if err != nil {
+		return nil, nil, err
+	}
+	pubKey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to recover signer: %v", err)
+	}
+	return pubKey, details, nil
+}
+
+// EthVerifyMessage recovers the signer of --signature over --message or
+// --typed_data and, with --address, checks that it signed
+func EthVerifyMessage() (*KeyPair, error) {
+	pubKey, details, err := ethRecover()
+	if err != nil {
+		return nil, err
+	}
+	signer := crypto.PubkeyToAddress(*pubKey)
+
+	if *addressFlag != "" {
+		address, err := parseEthAddress(*addressFlag)
+		if err != nil {
+			return nil, err
+		}
+		if address != signer {
+			return nil, fmt.Errorf("signature is by %s, not %s", checksumAddress(signer, *chainIDFlag), checksumAddress(address, *chainIDFlag))
+		}
+	}
+
+	details = append(details,
+		detail{"pubkey", hexutil.Encode(crypto.CompressPubkey(pubKey))},
+		detail{"signature", "valid"},
+	)
+	return &KeyPair{
+		network: "ethereum",
+		public:  checksumAddress(signer, *chainIDFlag),
+		details: details,
+	}, nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// quantity is a JSON number or a decimal or 0x hex string
+type quantity struct {
+	*big.Int
+}
+
+func (q *quantity) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return fmt.Errorf("invalid quantity %s", data)
+	}
+	q.Int = n
+	return nil
+}
+
+// uint64 returns the quantity as a uint64, 0 when missing
+func (q *quantity) uint64() (uint64, error) {
+	if q == nil {
+		return 0, nil
+	}
+	if !q.IsUint64() {
+		return 0, fmt.Errorf("quantity %s out of range", q)
+	}
+	return q.Uint64(), nil
+}
+
+// big returns the quantity, 0 when missing
+func (q *quantity) big() *big.Int {
+	if q == nil {
+		return new(big.Int)
+	}
+	return q.Int
+}
+
+// unsignedAuthorization is an EIP-7702 authorization. Without a signature
+// it is signed with the transaction key.
+type unsignedAuthorization struct {
+	ChainID *quantity `json:"chainId"`
+	Address string    `json:"address"`
+	Nonce   *quantity `json:"nonce"`
+	YParity *quantity `json:"yParity"`
+	R       *quantity `json:"r"`
+	S       *quantity `json:"s"`
+}
+
+// unsignedTx is the JSON transaction of sign-tx
+type unsignedTx struct {
+	Type                 *quantity               `json:"type"`
+	ChainID              *quantity               `json:"chainId"`
+	Nonce                *quantity               `json:"nonce"`
+	Gas                  *quantity               `json:"gas"`
+	GasLimit             *quantity               `json:"gasLimit"`
+	GasPrice             *quantity               `json:"gasPrice"`
+	MaxFeePerGas         *quantity               `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *quantity               `json:"maxPriorityFeePerGas"`
+	To                   string                  `json:"to"`
+	Value                *quantity               `json:"value"`
+	Data                 string                  `json:"data"`
+	AccessList           types.AccessList        `json:"accessList"`
+	AuthorizationList    []unsignedAuthorization `json:"authorizationList"`
+}
+
+// txType returns the type of the transaction, given or by its fields
+func (tx unsignedTx) txType() (uint64, error) {
+	switch {
+	case tx.Type != nil:
+		return tx.Type.uint64()
+	case tx.AuthorizationList != nil:
+		return types.SetCodeTxType, nil
+	case tx.MaxFeePerGas != nil:
+		return types.DynamicFeeTxType, nil
+	case tx.AccessList != nil:
+		return types.AccessListTxType, nil
+	default:
+		return types.LegacyTxType, nil
+	}
+}
+
+// txData converts the JSON transaction into go-ethereum transaction data,
+// signing the authorizations of a set code transaction with sign
+func (tx unsignedTx) txData(sign func(types.SetCodeAuthorization) (types.SetCodeAuthorization, error)) (types.TxData, error) {
+	txType, err := tx.txType()
+	if err != nil {
+		return nil, err
+	}
+	// Missing fields are not taken as 0, a wrong nonce or fee would still
+	// be signed
+	if tx.Nonce == nil {
+		return nil, errors.New("no nonce given")
+	}
+	switch txType {
+	case types.LegacyTxType, types.AccessListTxType:
+		if tx.GasPrice == nil {
+			return nil, errors.New("no gasPrice given")
+		}
+	default:
+		if tx.MaxFeePerGas == nil || tx.MaxPriorityFeePerGas == nil {
+			return nil, errors.New("no maxFeePerGas and maxPriorityFeePerGas given")
+		}
+	}
+	nonce, err := tx.Nonce.uint64()
+	if err != nil {
+		return nil, err
+	}
+	gasLimit := tx.Gas
+	if gasLimit == nil {
+		gasLimit = tx.GasLimit
+	}
+	gas, err := gasLimit.uint64()
+	if err != nil {
+		return nil, err
+	}
+	if gas == 0 {
+		return nil, errors.New("no gas limit given")
+	}
+	var to *common.Address
+	if tx.To != "" {
+		address, err := parseEthAddress(tx.To)
+		if err != nil {
+			return nil, err
+		}
+		to = &address
+	}
+	data, err := hexutil.Decode(tx.Data)
+	if tx.Data == "" {
+		data, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid data: %v", err)
+	}
+	if txType != types.LegacyTxType && tx.ChainID == nil {
+		return nil, errors.New("no chainId given")
+	}
+	chainID := tx.ChainID.big()
+
+	switch txType {
+	case types.LegacyTxType:
+		return &types.LegacyTx{Nonce: nonce, GasPrice: tx.GasPrice.big(), Gas: gas, To: to, Value: tx.Value.big(), Data: data}, nil
+	case types.AccessListTxType:
+		return &types.AccessListTx{ChainID: chainID, Nonce: nonce, GasPrice: tx.GasPrice.big(), Gas: gas, To: to,
+			Value: tx.Value.big(), Data: data, AccessList: tx.AccessList}, nil
+	case types.DynamicFeeTxType:
+		return &types.DynamicFeeTx{ChainID: chainID, Nonce: nonce, GasTipCap: tx.MaxPriorityFeePerGas.big(),
+			GasFeeCap: tx.MaxFeePerGas.big(), Gas: gas, To: to, Value: tx.Value.big(), Data: data, AccessList: tx.AccessList}, nil
+	case types.SetCodeTxType:
+		if to == nil {
+			return nil, errors.New("set code transactions can not create contracts")
+		}
+		if len(tx.AuthorizationList) == 0 {
+			return nil, errors.New("empty authorizationList")
+		}
+		authList := make([]types.SetCodeAuthorization, len(tx.AuthorizationList))
+		for i, a := range tx.AuthorizationList {
+			if authList[i], err = a.authorization(sign); err != nil {
+				return nil, fmt.Errorf("authorization %d: %v", i, err)
+			}
+		}
+		return &types.SetCodeTx{ChainID: uint256.MustFromBig(chainID), Nonce: nonce,
+			GasTipCap: uint256.MustFromBig(tx.MaxPriorityFeePerGas.big()), GasFeeCap: uint256.MustFromBig(tx.MaxFeePerGas.big()),
+			Gas: gas, To: *to, Value: uint256.MustFromBig(tx.Value.big()), Data: data, AccessList: tx.AccessList, AuthList: authList}, nil
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d, use 0, 1, 2 or 4", txType)
+	}
+}
+
+// authorization returns the signed authorization, signing it when no
+// signature is given
+func (a unsignedAuthorization) authorization(sign func(types.SetCodeAuthorization) (types.SetCodeAuthorization, error)) (types.SetCodeAuthorization, error) {
+	// Chain id 0 authorizes on every chain, so it must be given explicitly
+	if a.ChainID == nil {
+		return types.SetCodeAuthorization{}, errors.New("no chainId given, use 0 for an authorization valid on every chain")
+	}
+	if a.Nonce == nil {
+		return types.SetCodeAuthorization{}, errors.New("no nonce given")
+	}
+	address, err := parseEthAddress(a.Address)
+	if err != nil {
+		return types.SetCodeAuthorization{}, err
+	}
+	nonce, err := a.Nonce.uint64()
+	if err != nil {
+		return types.SetCodeAuthorization{}, err
+	}
+	auth := types.SetCodeAuthorization{
+		ChainID: *uint256.MustFromBig(a.ChainID.big()),
+		Address: address,
+		Nonce:   nonce,
+	}
+	if a.R == nil && a.S == nil && a.YParity == nil {
+		return sign(auth)
+	}
+	if a.R == nil || a.S == nil || a.YParity == nil {
+		return types.SetCodeAuthorization{}, errors.New("incomplete signature, give yParity, r and s")
+	}
+	yParity, err := a.YParity.uint64()
+	if err != nil || yParity > 1 {
+		return types.SetCodeAuthorization{}, errors.New("invalid yParity")
+	}
+	auth.V = uint8(yParity)
+	auth.R = *uint256.MustFromBig(a.R.big())
+	auth.S = *uint256.MustFromBig(a.S.big())
+	return auth, nil
+}
+
+// SignTx signs the JSON transaction --tx with the Ethereum key and prints
+// the raw transaction and its hash
+func SignTx() (*KeyPair, error) {
+	if *txFlag == "" {
+		return nil, errors.New("no transaction given, use --tx")
+	}
+	data, err := os.ReadFile(*txFlag)
+	if err != nil {
+		return nil, err
+	}
+	var tx unsignedTx
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %v", err)
+	}
+	privateKey, err := ethPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	txData, err := tx.txData(func(auth types.SetCodeAuthorization) (types.SetCodeAuthorization, error) {
+		return types.SignSetCode(privateKey, auth)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// A legacy transaction without chainId is signed without replay
+	// protection (pre EIP-155)
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.ChainID != nil {
+		signer = types.LatestSignerForChainID(tx.ChainID.Int)
+	}
+	signed, err := types.SignNewTx(privateKey, signer, txData)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	k := &KeyPair{
+		network: "ethereum",
+		public:  checksumAddress(crypto.PubkeyToAddress(privateKey.PublicKey), *chainIDFlag),
+		details: []detail{{"type", fmt.Sprint(signed.Type())}},
+	}
+	if signed.To() != nil {
+		k.details = append(k.details, detail{"to", checksumAddress(*signed.To(), *chainIDFlag)})
+	}
+	k.details = append(k.details,
+		detail{"hash", signed.Hash().Hex()},
+		detail{"raw", hexutil.Encode(raw)},
+	)
+	return k, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import "strings"
+
+// evmChain is an EVM chain sharing Ethereum's keys and addresses
+type evmChain struct {
+	name     string // network label
+	title    string
+	chainID  int64
+	coinType int    // SLIP-44 coin type of the default path
+	eip1191  bool   // addresses use EIP-1191 chain id checksums
+	explorer string // address page URL prefix
+}
+
+// evmChains is the registry of EVM chains, by network argument
+var evmChains = map[string]*evmChain{
+	"polygon":   {"polygon", "Polygon PoS", 137, 60, false, "https://polygonscan.com/address/"},
+	"bsc":       {"bsc", "BNB Smart Chain", 56, 60, false, "https://bscscan.com/address/"},
+	"arbitrum":  {"arbitrum", "Arbitrum One", 42161, 60, false, "https://arbiscan.io/address/"},
+	"optimism":  {"optimism", "OP Mainnet", 10, 60, false, "https://optimistic.etherscan.io/address/"},
+	"base":      {"base", "Base", 8453, 60, false, "https://basescan.org/address/"},
+	"avalanche": {"avalanche", "Avalanche C-Chain", 43114, 60, false, "https://snowtrace.io/address/"},
+	"etc":       {"etc", "Ethereum Classic", 61, 61, false, "https://etc.blockscout.com/address/"},
+	"rsk":       {"rsk", "Rootstock", 30, 137, true, "https://explorer.rootstock.io/address/"},
+}
+
+// evmChainAliases are the other names of the chains
+var evmChainAliases = map[string]string{
+	"matic":     "polygon",
+	"bnb":       "bsc",
+	"arb":       "arbitrum",
+	"op":        "optimism",
+	"avax":      "avalanche",
+	"classic":   "etc",
+	"rootstock": "rsk",
+}
+
+// evmChainByName returns the EVM chain of a network argument
+func evmChainByName(name string) (*evmChain, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := evmChainAliases[name]; ok {
+		name = alias
+	}
+	chain, ok := evmChains[name]
+	return chain, ok
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// descriptorRequest is one request of a Bitcoin Core importdescriptors call
+type descriptorRequest struct {
+	Desc      string `json:"desc"`
+	Timestamp any    `json:"timestamp"`
+	Range     []int  `json:"range,omitempty"`
+	Active    bool   `json:"active"`
+	Internal  bool   `json:"internal,omitempty"`
+	NextIndex *int   `json:"next_index,omitempty"`
+}
+
+// descriptorWrapper returns the descriptor function wrapping a key for the
+// address type, e.g. "sh(wpkh(%s))"
+func (btc bitcoin) descriptorWrapper() string {
+	switch {
+	case btc.isTaproot:
+		return "tr(%s)"
+	case btc.isNative:
+		return "wpkh(%s)"
+	case btc.isSegWit:
+		return "sh(wpkh(%s))"
+	default:
+		return "pkh(%s)"
+	}
+}
+
+// exportTimestamp returns --timestamp as a time and as the importdescriptors
+// value, "now" or a unix time. The exported keys already exist, so the
+// default 0 makes Bitcoin Core rescan for their history.
+func exportTimestamp() (time.Time, any, error) {
+	if *timestampFlag == "now" {
+		return time.Now().UTC(), "now", nil
+	}
+	unix, err := strconv.ParseInt(*timestampFlag, 10, 64)
+	if err != nil || unix < 0 {
+		return time.Time{}, nil, fmt.Errorf("invalid timestamp %q, use now or a unix time", *timestampFlag)
+	}
+	return time.Unix(unix, 0).UTC(), unix, nil
+}
+
+// exportAccount splits the derivation path of the export into the account
+// path and returns the account key derived from --custom_mnemonic. The
+// last two path levels must be the unhardened chain and index.
+func (btc bitcoin) exportAccount() (master, account *bip32.Key, accountPath string, err error) {
+	if !bip39.IsMnemonicValid(customMnemonic) {
+		return nil, nil, "", errors.New("invalid mnemonic phrase")
+	}
+	path := btc.derivationPath
+	if customPath != "" {
+		path = customPath
+	}
+	components := strings.Split(path, "/")
+	if len(components) < 3 || strings.HasSuffix(components[len(components)-1], "'") ||
+		strings.HasSuffix(components[len(components)-2], "'") {
+		return nil, nil, "", fmt.Errorf("derivation path %q does not end in an unhardened chain and index", path)
+	}
+	accountPath = strings.Join(components[:len(components)-2], "/")
+
+	master, err = bip32.NewMasterKey(bip39.NewSeed(customMnemonic, ""))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	account, err = btc.deriveChildKeyFromMaster(master, accountPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return master, account, accountPath, nil
+}
+
+// writeExport writes data to --out, or prints it
+func writeExport(data string) error {
+	if *outFlag == "" {
+		fmt.Print(data)
+		return nil
+	}
+	if err := os.WriteFile(*outFlag, []byte(data), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("%-3s %-12s %s\n", "export", "written", *outFlag)
+	return nil
+}
+
+// ExportDescriptors writes an importdescriptors request for the --type keys
+// of --custom_mnemonic (receive and change chains) or --custom_private
+func ExportDescriptors() error {
+	btc, ok := bitcoinByName(*typeFlag)
+	if !ok {
+		return fmt.Errorf("unknown address type %q", *typeFlag)
+	}
+	if *rangeFlag < 0 {
+		return errors.New("invalid range")
+	}
+	_, timestamp, err := exportTimestamp()
+	if err != nil {
+		return err
+	}
+
+	var requests []descriptorRequest
+	switch {
+	case customMnemonic != "":
+		master, account, accountPath, err := btc.exportAccount()
+		if err != nil {
+			return err
+		}
+		origin := originNotation(fingerprint(master), accountPath)
+		nextIndex := 0
+		for chain, internal := range []bool{false, true} {
+			key := fmt.Sprintf("%s%s/%d/*", origin, account.B58Serialize(), chain)
+			requests = append(requests, descriptorRequest{
+				Desc:      addDescriptorChecksum(fmt.Sprintf(btc.descriptorWrapper(), key)),
+				Timestamp: timestamp,
+				Range:     []int{0, *rangeFlag},
+				Active:    true,
+				Internal:  internal,
+				NextIndex: &nextIndex,
+			})
+		}
+	case customPrivate != "":
+		wif, err := btcutil.DecodeWIF(customPrivate)
+		if err != nil {
+			return fmt.Errorf("failed to decode WIF private key: %v", err)
+		}
+		if btc.isSegWit && !wif.CompressPubKey {
+			return errors.New("segwit addresses require a compressed private key")
+		}
+		// Single keys can not be active
+		requests = append(requests, descriptorRequest{
+			Desc:      addDescriptorChecksum(fmt.Sprintf(btc.descriptorWrapper(), wif.String())),
+			Timestamp: timestamp,
+		})
+	default:
+		return errors.New("no key given, use --custom_private or --custom_mnemonic")
+	}
+
+	data, err := json.MarshalIndent(requests, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeExport(string(data) + "\n")
+}
+
+// dumpWalletLine formats one key line of a dumpwallet file. Every address
+// type of the key is listed as Bitcoin Core does for legacy wallets, an
+// uncompressed key only has its P2PKH address.
+func dumpWalletLine(wif *btcutil.WIF, created time.Time, kind, hdKeyPath string) (string, error) {
+	var addrs []string
+	if wif.CompressPubKey {
+		keyPairs, err := allKeyPairs(wif)
+		if err != nil {
+			return "", err
+		}
+		for _, k := range keyPairs {
+			if k.network != btcMap["taproot"].name {
+				addrs = append(addrs, k.public)
+			}
+		}
+	} else {
+		address, err := btcMap["legacy"].pubKeyAddress(wif.PrivKey.PubKey(), false)
+		if err != nil {
+			return "", err
+		}
+		addrs = append(addrs, address.EncodeAddress())
+	}
+	line := fmt.Sprintf("%s %s %s # addr=%s", wif.String(), created.Format(time.RFC3339), kind, strings.Join(addrs, ","))
+	if hdKeyPath != "" {
+		line += " hdkeypath=" + hdKeyPath
+	}
+	return line + "\n", nil
+}
+
+// ExportDumpWallet writes a dumpwallet text file with the --type keys of
+// --custom_mnemonic (receive and change 0 to --range) or --custom_private
+func ExportDumpWallet() error {
+	btc, ok := bitcoinByName(*typeFlag)
+	if !ok {
+		return fmt.Errorf("unknown address type %q", *typeFlag)
+	}
+	if *rangeFlag < 0 {
+		return errors.New("invalid range")
+	}
+	created, _, err := exportTimestamp()
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Wallet dump created by generateKeys\n")
+	sb.WriteString("# * Created on " + time.Now().UTC().Format(time.RFC3339) + "\n")
+
+	switch {
+	case customMnemonic != "":
+		master, account, accountPath, err := btc.exportAccount()
+		if err != nil {
+			return err
+		}
+		sb.WriteString("# * Master key fingerprint: " + fingerprint(master) + "\n")
+		sb.WriteString("# extended private masterkey: " + master.B58Serialize() + "\n\n")
+		for chain := uint32(0); chain < 2; chain++ {
+			chainKey, err := account.NewChildKey(chain)
+			if err != nil {
+				return err
+			}
+			// Bitcoin Core marks the internal chain as change
+			kind := "reserve=1"
+			if chain == 1 {
+				kind = "change=1"
+			}
+			for i := 0; i <= *rangeFlag; i++ {
+				child, err := chainKey.NewChildKey(uint32(i))
+				if err != nil {
+					return err
+				}
+				privKey, _ := btcec.PrivKeyFromBytes(child.Key)
+				wif, err := btcutil.NewWIF(privKey, &chaincfg.MainNetParams, true)
+				if err != nil {
+					return err
+				}
+				line, err := dumpWalletLine(wif, created, kind, fmt.Sprintf("%s/%d/%d", accountPath, chain, i))
+				if err != nil {
+					return err
+				}
+				sb.WriteString(line)
+			}
+		}
+	case customPrivate != "":
+		wif, err := btcutil.DecodeWIF(customPrivate)
+		if err != nil {
+			return fmt.Errorf("failed to decode WIF private key: %v", err)
+		}
+		line, err := dumpWalletLine(wif, created, "label=", "")
+		if err != nil {
+			return err
+		}
+		sb.WriteString("\n" + line)
+	default:
+		return errors.New("no key given, use --custom_private or --custom_mnemonic")
+	}
+
+	sb.WriteString("\n# End of dump\n")
+	return writeExport(sb.String())
+}
+
+// ImportDumpWallet lists the addresses of every key in the dumpwallet file
+// --dump
+func ImportDumpWallet() error {
+	file, err := os.Open(*dumpFlag)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber, keys := 0, 0
+	masterFingerprint := ""
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		// The master key gives the fingerprint of the key origins
+		if xprv, ok := strings.CutPrefix(line, "# extended private masterkey: "); ok {
+			master, err := bip32.B58Deserialize(xprv)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid master key: %v", lineNumber, err)
+			}
+			masterFingerprint = fingerprint(master)
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// <wif> <time> <kind> # addr=<addrs> [hdkeypath=<path>]
+		fields := strings.Fields(line)
+		wif, err := btcutil.DecodeWIF(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid private key: %v", lineNumber, err)
+		}
+		if !wif.IsForNet(&chaincfg.MainNetParams) {
+			return fmt.Errorf("line %d: private key is not for mainnet", lineNumber)
+		}
+		keyPairs, err := allKeyPairs(wif)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		PrintAll(keyPairs)
+		for _, field := range fields[1:] {
+			if path, ok := strings.CutPrefix(field, "hdkeypath="); ok {
+				fmt.Printf("%-29s %-12s %s\n", "bitcoin", "derivation", path)
+				if masterFingerprint != "" {
+					fmt.Printf("%-29s %-12s %s\n", "bitcoin", "key origin", originNotation(masterFingerprint, path))
+				}
+			}
+		}
+		fmt.Println("")
+		keys++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if keys == 0 {
+		return errors.New("no keys found in the dump file")
+	}
+	return nil
+}
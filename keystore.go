@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Web3 Secret Storage defaults, the geth standard scrypt cost
+const (
+	keystoreCost    = 262144
+	keystoreScryptR = 8
+	keystoreScryptP = 1
+	keystoreKeyLen  = 32
+)
+
+// keystoreFile is a version 3 Web3 Secret Storage file
+type keystoreFile struct {
+	Address string         `json:"address"`
+	Crypto  keystoreCrypto `json:"crypto"`
+	ID      string         `json:"id"`
+	Version int            `json:"version"`
+}
+
+type keystoreCrypto struct {
+	Cipher       string `json:"cipher"`
+	CipherText   string `json:"ciphertext"`
+	CipherParams struct {
+		IV string `json:"iv"`
+	} `json:"cipherparams"`
+	KDF       string         `json:"kdf"`
+	KDFParams keystoreParams `json:"kdfparams"`
+	MAC       string         `json:"mac"`
+}
+
+// keystoreParams holds the parameters of both KDFs, scrypt (n, r, p) and
+// pbkdf2 (c, prf)
+type keystoreParams struct {
+	DKLen int    `json:"dklen"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+	Salt  string `json:"salt"`
+}
+
+// newKeystoreParams returns new --kdf parameters with the --kdf_cost and a
+// random salt
+func newKeystoreParams() (keystoreParams, error) {
+	cost := *kdfCostFlag
+	if cost == 0 {
+		cost = keystoreCost
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return keystoreParams{}, err
+	}
+
+	params := keystoreParams{DKLen: keystoreKeyLen, Salt: hex.EncodeToString(salt)}
+	switch *kdfFlag {
+	case "scrypt":
+		// scrypt needs a power of two cost
+		if cost < 2 || cost&(cost-1) != 0 {
+			return keystoreParams{}, fmt.Errorf("invalid scrypt cost %d, use a power of two", cost)
+		}
+		params.N, params.R, params.P = cost, keystoreScryptR, keystoreScryptP
+	case "pbkdf2":
+		if cost < 1 {
+			return keystoreParams{}, fmt.Errorf("invalid pbkdf2 cost %d", cost)
+		}
+		params.C, params.PRF = cost, "hmac-sha256"
+	default:
+		return keystoreParams{}, fmt.Errorf("unknown kdf %q, use scrypt or pbkdf2", *kdfFlag)
+	}
+	return params, nil
+}
+
+// deriveKey runs kdf with the parameters on password
+func (params keystoreParams) deriveKey(kdf string, password []byte) ([]byte, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore salt: %v", err)
+	}
+	if params.DKLen < 32 {
+		return nil, fmt.Errorf("invalid keystore key length %d", params.DKLen)
+	}
+	switch kdf {
+	case "scrypt":
+		return scrypt.Key(password, salt, params.N, params.R, params.P, params.DKLen)
+	case "pbkdf2":
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported keystore prf %q", params.PRF)
+		}
+		if params.C <= 0 {
+			return nil, fmt.Errorf("invalid keystore iteration count %d", params.C)
+		}
+		return pbkdf2.Key(password, salt, params.C, params.DKLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported keystore kdf %q", kdf)
+	}
+}
+
+// aesCTR encrypts or decrypts data with AES-128-CTR
+func aesCTR(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCTR(block, iv).XORKeyStream(out, data)
+	return out, nil
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+// encryptKeystore encrypts privateKey with password into a V3 keystore,
+// with the --kdf and --kdf_cost parameters
+func encryptKeystore(privateKey *ecdsa.PrivateKey, password string) ([]byte, error) {
+	params, err := newKeystoreParams()
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	var c keystoreCrypto
+	c.Cipher = "aes-128-ctr"
+	c.CipherParams.IV = hex.EncodeToString(iv)
+	c.KDF = *kdfFlag
+	c.KDFParams = params
+
+	derivedKey, err := c.KDFParams.deriveKey(c.KDF, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := aesCTR(derivedKey[:16], iv, crypto.FromECDSA(privateKey))
+	if err != nil {
+		return nil, err
+	}
+	c.CipherText = hex.EncodeToString(cipherText)
+	c.MAC = hex.EncodeToString(crypto.Keccak256(derivedKey[16:32], cipherText))
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+	return json.Marshal(keystoreFile{
+		Address: hex.EncodeToString(address[:]),
+		Crypto:  c,
+		ID:      id,
+		Version: 3,
+	})
+}
+
+// decryptKeystore decrypts a V3 keystore with password
+func decryptKeystore(data []byte, password string) (*ecdsa.PrivateKey, error) {
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+	if file.Version != 3 {
+		return nil, fmt.Errorf("unsupported keystore version %d", file.Version)
+	}
+	c := file.Crypto
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported keystore cipher %q", c.Cipher)
+	}
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore ciphertext: %v", err)
+	}
+	iv, err := hex.DecodeString(c.CipherParams.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid keystore iv")
+	}
+	mac, err := hex.DecodeString(c.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore mac: %v", err)
+	}
+
+	derivedKey, err := c.KDFParams.deriveKey(c.KDF, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crypto.Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, errors.New("could not decrypt keystore with the given password")
+	}
+	plainText, err := aesCTR(derivedKey[:16], iv, cipherText)
+	if err != nil {
+		return nil, err
+	}
+	// Old geth versions wrote keys with leading zero bytes stripped
+	if len(plainText) < 32 {
+		plainText = append(make([]byte, 32-len(plainText)), plainText...)
+	}
+	privateKey, err := crypto.ToECDSA(plainText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore private key: %v", err)
+	}
+
+	// The address is optional, but must match when present
+	if file.Address != "" && !strings.EqualFold(strings.TrimPrefix(file.Address, "0x"),
+		hex.EncodeToString(crypto.PubkeyToAddress(privateKey.PublicKey).Bytes())) {
+		return nil, errors.New("keystore address does not match its private key")
+	}
+	return privateKey, nil
+}
+
+// isKeystoreFile reports whether path is a JSON file with a crypto section
+func isKeystoreFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	// Field names match case-insensitively, some wallets write "Crypto"
+	var file keystoreFile
+	return json.Unmarshal(data, &file) == nil && file.Crypto.Cipher != ""
+}
+
+// readKeystoreFile decrypts the keystore file at path after prompting for
+// its password and returns the private key in hex
+func readKeystoreFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	password, err := readPassphrase("Keystore password: ", false)
+	if err != nil {
+		return "", err
+	}
+	privateKey, err := decryptKeystore(data, password)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(crypto.FromECDSA(privateKey)), nil
+}
+
+// keystoreFileName returns the geth file name UTC--<time>--<address>
+func keystoreFileName(address common.Address, t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("UTC--%04d-%02d-%02dT%02d-%02d-%02d.%09dZ--%s",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(),
+		hex.EncodeToString(address[:]))
+}
+
+// writeKeystore encrypts privateKey with a new password into a keystore
+// file in the --keystore directory and returns its path
+func writeKeystore(privateKey *ecdsa.PrivateKey) (string, error) {
+	password, err := readPassphrase("New keystore password: ", true)
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	data, err := encryptKeystore(privateKey, password)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(*keystoreFlag, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(*keystoreFlag, keystoreFileName(crypto.PubkeyToAddress(privateKey.PublicKey), time.Now()))
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
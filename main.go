@@ -15,6 +15,15 @@ type KeyPair struct {
 	private        string
 	mnemonic       string
 	derivationPath string
+	keyFormat      string
+	origin         *keyOrigin
+	details        []detail
+}
+
+// detail is an extra labelled value printed with a key pair
+type detail struct {
+	label string
+	value string
 }
 
 func Usage() {
@@ -27,18 +36,138 @@ Network (required as first argument):
   btcn, native             Native SegWit (P2WPKH, Bech32): More efficient and secure, lower fees.
   btcs, segwit             SegWit (P2SH-wrapped P2WPKH): SegWit compatibility, lower fees.
   btct, taproot            Taproot (P2TR): Latest Bitcoin upgrade, more privacy and efficiency.
-  eth, ethereum            Ethereum
+  btca, btc-all            All Bitcoin address types for one private key (use with --custom_private).
+  eth, ethereum            Ethereum (EIP-55 checksummed address).
   sol, solana              Solana
+  polygon, bsc, arbitrum, optimism, base, avalanche, etc, rsk
+                           EVM chains: Ethereum keys with the chain's default path
+                           (etc m/44'/61', rsk m/44'/137' with EIP-1191 checksums),
+                           chain id and explorer link. Takes the Ethereum options.
+  msig, multisig           Multisig sortedmulti addresses from cosigner xpubs.
+  musig, musig2            MuSig2 aggregate key taproot address.
+  sp, silent               Silent Payments (BIP-352) address.
+  pc, bip47                BIP-47 payment code and notification address.
+  el, electrum             Electrum seed (v1 or v2 standard/segwit) receive and change address.
+                           Generates a new seed unless --custom_mnemonic is given
+                           (--type legacy for a standard, native for a segwit seed).
+  eth2, validator          Ethereum validator (EIP-2333/2334) BLS signing and withdrawal keys.
+                           With --keystore, EIP-2335 keystores and deposit_data.json are written.
+      --validators <n>     Number of validators from --index (default 1).
+      --fork_version <v>   Deposit fork version, mainnet (default), sepolia, holesky, hoodi or hex.
+      --withdrawal_address <address>
+                           0x01 withdrawal credentials to an execution address
+                           (default 0x00 credentials of the BLS withdrawal key).
 
 Option:
-  -a, --all                Prints mnemonic and derivation path.
+  -a, --all                Prints mnemonic, derivation path, master fingerprint and key origin.
   -i, --include <include>  Include words in public key (comma-separated).
       --prefix             Addon for include.
       --postfix            Addon for include.
                            Example: -i abcde,10000
   --custom_mnemonic        Use custom mnemonic.
   --custom_path            Use custom derivation path.
-  --custom_private         Use custom private key.
+  --custom_private         Use custom private key. BIP-38 keys (6P...) and Ethereum keystore
+                           files prompt for the passphrase.
+  --bip38                  Encrypt the Bitcoin private key with a BIP-38 passphrase.
+  --uncompressed           Use an uncompressed public key (legacy only).
+  --tapleaf <script>       Add a tapleaf script to the taproot address (repeatable).
+                           Hex script, pk(KEY), and(pk(KEY),older(N)) or and(pk(KEY),after(N)).
+  --nums                   Use the BIP-341 NUMS point as taproot internal key.
+  --xpub <xpub>            Cosigner account xpub for multisig, optionally with
+                           key origin [fingerprint/48'/0'/0'/2']xpub (repeatable).
+  --threshold <m>          Required signatures for multisig and Safes.
+  --index <i>              Receive address index for multisig, payment codes, Ethereum
+                           path presets and the first validator.
+  --pubkey <hex>           Public key of a remote musig2 party (repeatable).
+  --signer <key>           WIF or "mnemonic[|path]" of a local musig2 party (repeatable).
+  --payment_code <code>    Counterparty payment code for the shared send and receive
+                           addresses at --index.
+  --keystore <dir>         Write the Ethereum key to an encrypted keystore file
+                           (UTC--<time>--<address>, EIP-2335 for eth2) instead of printing it.
+      --kdf <kdf>          Keystore key derivation, scrypt (default) or pbkdf2.
+      --kdf_cost <n>       scrypt N (a power of two) or pbkdf2 iterations (default 262144).
+  --path_preset <wallet>   Ethereum path of account --index in the wallet's convention:
+                           metamask m/44'/60'/0'/0/i, ledger-live m/44'/60'/i'/0/0 or
+                           ledger-legacy m/44'/60'/0'/i.
+      --accounts <n>       List the addresses of the first n accounts of the mnemonic with
+                           --path_preset, or with all three presets when it is not given.
+  --chain_id <id>          EIP-1191 chain id for Ethereum address checksums (RSK 30, 31).
+  --message <message>      Message to sign or verify (32-byte hash in hex for musig2).
+
+Commands (used instead of a network):
+  sign-message             Sign --message with --custom_private or --custom_mnemonic.
+      --type <network>     Bitcoin address type to sign for (default native).
+      --format <format>    BIP-322 simple (default) or full. Legacy always uses full.
+                           BIP-137 compact signatures with bip137, trezor or electrum.
+  verify-message           Verify a BIP-322 or BIP-137 --signature of --message for --address.
+  sign-psbt                Sign the inputs of a PSBT (version 0 or 2) matching the key of
+                           --custom_mnemonic or --custom_private (WIF or xprv).
+      --psbt <psbt>        PSBT file (binary or base64) or base64 string.
+      --out <file>         Write the signed PSBT to a file instead of printing it.
+  sweep                    Build and sign a transaction sending all utxos of --custom_private.
+      --utxos <file>       JSON list of {"txid", "vout", "value" (sat), "scriptPubKey" (hex)}.
+      --to <address>       Destination address.
+      --fee_rate <rate>    Fee rate in sat/vB, at least the 1 sat/vB minimum relay fee.
+  silent-scan              Find silent payments to the keys of --custom_mnemonic.
+      --txs <file>         JSON list of {"txid", "inputs": [{"txid", "vout", "pubkey"}],
+                           "outputs": [x-only key or P2TR scriptPubKey]}.
+      --labels <n>         Also scan for labels 1 to n (change label 0 is always scanned).
+  bip38-intermediate       Create a BIP-38 intermediate code from a passphrase.
+      --lot <n>            Lot number (0 to 1048575) encoded in the code.
+      --sequence <n>       Sequence number (0 to 4095) within the lot.
+  bip38-generate           Create an encrypted key and confirmation code from --code
+                           (intermediate code) without knowing the passphrase.
+  bip38-confirm            Check a confirmation --code with the passphrase.
+  eth-sign-message         Sign --message (EIP-191 personal_sign, 0x... as bytes) with the
+                           Ethereum key of --custom_private or --custom_mnemonic.
+      --typed_data <file>  Sign EIP-712 typed data (eth_signTypedData_v4 JSON) instead.
+  eth-verify-message       Recover the signer of an Ethereum --signature over --message or
+                           --typed_data (--format hash for a 32-byte hash), and check it is
+                           --address when given.
+  ecrecover                Recover the public key and Ethereum address of a --signature over
+                           --message or --typed_data (--format hash for a 32-byte hash).
+  pubkey-to-address        Print the addresses of every --pubkey (repeatable) on all networks:
+                           secp256k1 keys (compressed, uncompressed, or 64 bytes without 04)
+                           for Bitcoin, Ethereum, Tron and Cosmos, 32-byte ed25519 keys for
+                           Solana. Keys are hex or base58.
+      --xonly              Decode 32-byte keys as x-only secp256k1 (taproot) keys, which
+                           only have a taproot address, instead of ed25519.
+  cross-chain              Print the addresses of the secp256k1 key --custom_private (WIF or
+                           hex) on Bitcoin, Ethereum and the EVM chains, Tron and Cosmos.
+  sign-tx                  Sign an Ethereum transaction with the key of --custom_private or
+                           --custom_mnemonic and print the raw transaction and its hash.
+      --tx <file>          JSON {"type" (0, 1, 2 or 4), "chainId", "nonce", "gas", "gasPrice" or
+                           "maxFeePerGas" and "maxPriorityFeePerGas", "to", "value", "data",
+                           "accessList", "authorizationList": [{"chainId", "address", "nonce"}]}.
+                           Numbers are decimal or 0x hex. The nonce and fees are required,
+                           "value" and "data" default to empty. Authorizations need an explicit
+                           "chainId" (0 is valid on every chain) and without "yParity", "r"
+                           and "s" are signed with the same key.
+  smart-account            Compute the CREATE2 address of a smart account owned by the Ethereum
+                           key of --custom_private or --custom_mnemonic, or by --address.
+      --factory <address>  Deploying factory.
+      --salt <n>           CREATE2 salt, or the Safe salt nonce (default 0).
+      --init_code <code>   Account creation code (hex or file), {owner} is replaced by the
+                           32-byte owner (ERC-4337 factories).
+      --singleton <address>
+                           Safe singleton, for a Safe created with createProxyWithNonce.
+      --proxy_code <code>  Safe proxy creation code (hex or file), proxyCreationCode() of the factory.
+      --owner <address>    Additional Safe owner (repeatable), with --threshold (default 1)
+                           and --fallback_handler <address>.
+  eth-address              Validate the checksum of an Ethereum --address and print its
+                           EIP-55 (and EIP-1191 with --chain_id) encodings.
+  export-descriptors       Write a Bitcoin Core importdescriptors request for the --type keys of
+                           --custom_mnemonic (receive and change chain) or --custom_private.
+      --timestamp <time>   Key creation time as a unix time, 0 (default) rescans the whole
+                           chain. now skips the history, only for keys that were never used.
+      --range <n>          Last index of the descriptor range (default 999).
+      --out <file>         Write to a file instead of printing.
+  export-dumpwallet        Write a Bitcoin Core dumpwallet file for the same keys, with
+                           --timestamp, --range (receive and change 0 to n) and --out.
+  export-electrum          Write an Electrum wallet file for the Electrum seed --custom_mnemonic
+                           (to --out or printed).
+  import-dumpwallet        List the addresses of every key in a dumpwallet file.
+      --dump <file>        dumpwallet file.
 `, os.Args[0])
 	os.Exit(1)
 }
@@ -46,13 +175,42 @@ Option:
 // Print to std.out
 func (k KeyPair) Print() {
 	fmt.Printf("%-3s %-12s %s\n", k.network, "public", k.public)
-	fmt.Printf("%-3s %-12s %s\n", k.network, "private", k.private)
+	if k.private != "" {
+		fmt.Printf("%-3s %-12s %s\n", k.network, "private", k.private)
+	}
+	if k.keyFormat != "" {
+		fmt.Printf("%-3s %-12s %s\n", k.network, "key format", k.keyFormat)
+	}
 	if k.mnemonic != "" {
 		fmt.Printf("%-3s %-12s %s\n", k.network, "mnemonic", k.mnemonic)
-		if k.derivationPath != "" {
-			fmt.Printf("%-3s %-12s %s\n", k.network, "derivation", k.derivationPath)
+	}
+	if k.derivationPath != "" && (k.mnemonic != "" || k.private == "") {
+		fmt.Printf("%-3s %-12s %s\n", k.network, "derivation", k.derivationPath)
+	}
+	if k.origin != nil {
+		for _, d := range k.origin.details() {
+			fmt.Printf("%-3s %-12s %s\n", k.network, d.label, d.value)
 		}
 	}
+	for _, d := range k.details {
+		fmt.Printf("%-3s %-12s %s\n", k.network, d.label, d.value)
+	}
+}
+
+// PrintAll prints the public keys of several key pairs sharing one key
+func PrintAll(keyPairs []*KeyPair) {
+	if len(keyPairs) == 0 {
+		return
+	}
+	for _, k := range keyPairs {
+		fmt.Printf("%-29s %-12s %s\n", k.network, "public", k.public)
+		if k.keyFormat != "" {
+			fmt.Printf("%-29s %-12s %s\n", k.network, "key format", k.keyFormat)
+		}
+	}
+	if keyPairs[0].private != "" {
+		fmt.Printf("%-29s %-12s %s\n", "bitcoin", "private", keyPairs[0].private)
+	}
 }
 
 type Network interface {
@@ -70,11 +228,66 @@ var (
 	customMnemonicFlag = flag.String("custom_mnemonic", "", "Custom mnemonic phrase for key generation.")
 	customPathFlag     = flag.String("custom_path", "", "Custom derivation path for key generation.")
 	customPrivateFlag  = flag.String("custom_private", "", "Custom private key for key generation.")
+	uncompressedFlag   = flag.Bool("uncompressed", false, "Use an uncompressed public key for legacy addresses.")
+	numsFlag           = flag.Bool("nums", false, "Use the BIP-341 NUMS point as taproot internal key.")
+	thresholdFlag      = flag.Int("threshold", 0, "Required signatures for multisig and Safes.")
+	indexFlag          = flag.Int("index", 0, "Receive address index for multisig, payment codes, Ethereum path presets and validators.")
+	tapLeafFlag        listFlag
+	messageFlag        = flag.String("message", "", "Message to sign or verify (32-byte hash in hex for musig2).")
+	typeFlag           = flag.String("type", "native", "Bitcoin address type for messages, exports and new Electrum seeds.")
+	formatFlag         = flag.String("format", "simple", "Message signature format.")
+	addressFlag        = flag.String("address", "", "Address to verify a message signature for, or the smart account owner.")
+	signatureFlag      = flag.String("signature", "", "Message signature to verify.")
+	psbtFlag           = flag.String("psbt", "", "PSBT file or base64 string to sign.")
+	outFlag            = flag.String("out", "", "Output file.")
+	utxosFlag          = flag.String("utxos", "", "JSON file with the utxos to sweep.")
+	toFlag             = flag.String("to", "", "Destination address.")
+	feeRateFlag        = flag.Float64("fee_rate", 0, "Fee rate in sat/vB.")
+	txsFlag            = flag.String("txs", "", "JSON file with the transactions to scan.")
+	paymentCodeFlag    = flag.String("payment_code", "", "Counterparty BIP-47 payment code.")
+	bip38Flag          = flag.Bool("bip38", false, "Encrypt the private key with a BIP-38 passphrase.")
+	codeFlag           = flag.String("code", "", "BIP-38 intermediate or confirmation code.")
+	lotFlag            = flag.Int("lot", 0, "BIP-38 lot number.")
+	sequenceFlag       = flag.Int("sequence", 0, "BIP-38 sequence number.")
+	timestampFlag      = flag.String("timestamp", "0", "Key creation time for exports, a unix time (0 rescans everything) or now.")
+	rangeFlag          = flag.Int("range", 999, "Last address index of exported descriptors and dumps.")
+	dumpFlag           = flag.String("dump", "", "dumpwallet file to import.")
+	keystoreFlag       = flag.String("keystore", "", "Directory to write an encrypted Ethereum keystore file to.")
+	kdfFlag            = flag.String("kdf", "scrypt", "Keystore key derivation function, scrypt or pbkdf2.")
+	kdfCostFlag        = flag.Int("kdf_cost", 0, "Keystore scrypt N or pbkdf2 iterations (default 262144).")
+	txFlag             = flag.String("tx", "", "JSON file with the Ethereum transaction to sign.")
+	typedDataFlag      = flag.String("typed_data", "", "EIP-712 typed data JSON file to sign or verify.")
+	pathPresetFlag     = flag.String("path_preset", "", "Ethereum wallet path convention, metamask, ledger-live or ledger-legacy.")
+	accountsFlag       = flag.Int("accounts", 0, "Number of Ethereum accounts to list.")
+	validatorsFlag     = flag.Int("validators", 1, "Number of validator keys to derive.")
+	forkVersionFlag    = flag.String("fork_version", "mainnet", "Deposit fork version, a network name or 4 bytes of hex.")
+	withdrawalAddrFlag = flag.String("withdrawal_address", "", "Execution address of 0x01 validator withdrawal credentials.")
+	factoryFlag        = flag.String("factory", "", "CREATE2 factory address of the smart account.")
+	initCodeFlag       = flag.String("init_code", "", "Smart account creation code (hex or file) with {owner} placeholders.")
+	saltFlag           = flag.String("salt", "0", "CREATE2 salt or Safe salt nonce.")
+	singletonFlag      = flag.String("singleton", "", "Safe singleton address.")
+	proxyCodeFlag      = flag.String("proxy_code", "", "Safe proxy creation code (hex or file).")
+	fallbackFlag       = flag.String("fallback_handler", "", "Safe fallback handler address.")
+	chainIDFlag        = flag.Int64("chain_id", 0, "EIP-1191 chain id of Ethereum address checksums.")
+	labelsFlag         = flag.Int("labels", 0, "Highest silent payment label to scan for.")
+	xOnlyFlag          = flag.Bool("xonly", false, "Decode 32-byte public keys as x-only secp256k1 (taproot) keys.")
+	xpubFlag           listFlag
+	pubKeyFlag         listFlag
+	signerFlag         listFlag
+	ownerFlag          listFlag
 	customMnemonic     string
 	customPath         string
 	customPrivate      string
 )
 
+func init() {
+	flag.Var(&tapLeafFlag, "tapleaf", "Tapleaf script or policy for the taproot address (repeatable).")
+	flag.Var(&xpubFlag, "xpub", "Cosigner account xpub for multisig (repeatable).")
+	flag.Var(&pubKeyFlag, "pubkey", "Public key of a remote musig2 party or to convert (repeatable).")
+	flag.Var(&ownerFlag, "owner", "Additional Safe owner address (repeatable).")
+	flag.Var(&signerFlag, "signer", "WIF or mnemonic of a local musig2 party (repeatable).")
+}
+
 func main() {
 	flag.Usage = Usage
 	// Manually parse the arguments to separate the network argument from the flags
@@ -104,23 +317,184 @@ func main() {
 	customPath = *customPathFlag
 	customPrivate = *customPrivateFlag
 
+	// Decrypt a BIP-38 private key before any network uses it
+	if isBIP38(customPrivate) {
+		passphrase, err := readBIP38Passphrase(false)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		wif, err := decryptBIP38(customPrivate, passphrase)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		customPrivate = wif.String()
+	}
+
+	// Decrypt an Ethereum keystore file given as the private key
+	if isKeystoreFile(customPrivate) {
+		privateKey, err := readKeystoreFile(customPrivate)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		customPrivate = privateKey
+	}
+
 	// Proceed with the rest of the program
 	var network Network
 	switch strings.ToLower(networkArg) {
-	case "btc", "legacy", "bitcoin":
-		network = btcMap["legacy"]
-	case "btcs", "segwit":
-		network = btcMap["segwit"]
-	case "btcn", "native":
-		network = btcMap["native"]
-	case "btct", "taproot":
-		network = btcMap["taproot"]
+	case "sign-message":
+		keyPair, err := SignMessage()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "verify-message":
+		keyPair, err := VerifyMessage()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "eth-sign-message":
+		keyPair, err := EthSignMessage()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "eth-verify-message":
+		keyPair, err := EthVerifyMessage()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "sign-tx":
+		keyPair, err := SignTx()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "smart-account":
+		keyPair, err := SmartAccountAddress()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "pubkey-to-address":
+		if err := PubKeyToAddress(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "cross-chain":
+		if err := CrossChain(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "ecrecover":
+		keyPair, err := EcRecover()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "sign-psbt":
+		if err := SignPSBT(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "sweep":
+		if err := Sweep(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "silent-scan":
+		if err := SilentScan(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "bip38-intermediate":
+		if err := BIP38Intermediate(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "bip38-generate":
+		keyPair, err := BIP38Generate()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "bip38-confirm":
+		keyPair, err := BIP38Confirm()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "export-descriptors":
+		if err := ExportDescriptors(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "export-dumpwallet":
+		if err := ExportDumpWallet(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "import-dumpwallet":
+		if err := ImportDumpWallet(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "export-electrum":
+		if err := ExportElectrum(); err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		return
+	case "eth-address":
+		keyPair, err := EthAddress()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		keyPair.Print()
+		return
+	case "btca", "btc-all":
+		keyPairs, err := GenerateAllKeys()
+		if err != nil {
+			log.Fatalln(networkArg, err)
+		}
+		PrintAll(keyPairs)
+		return
 	case "eth", "ethereum":
 		network = &ethereum{}
 	case "sol", "solana":
 		network = &solana{}
+	case "msig", "multisig":
+		network = &multisig{}
+	case "musig", "musig2":
+		network = &musig{}
+	case "sp", "silent":
+		network = &silent{}
+	case "pc", "bip47":
+		network = &paymentCode{}
+	case "el", "electrum":
+		network = &electrum{}
+	case "eth2", "validator":
+		network = &eth2{}
 	default:
-		log.Fatalf("%q not found\n", networkArg)
+		if chain, ok := evmChainByName(networkArg); ok {
+			network = &ethereum{chain: chain}
+			break
+		}
+		btc, ok := bitcoinByName(networkArg)
+		if !ok {
+			log.Fatalf("%q not found\n", networkArg)
+		}
+		network = btc
 	}
 
 	include := *includeFlag
@@ -132,6 +506,25 @@ func main() {
 	if include != "" && len(includeWords) == 0 {
 		log.Fatalln("no words to include")
 	}
+	if include != "" && *keystoreFlag != "" {
+		log.Fatalln("--keystore can not be used with --include")
+	}
+	// BIP-38 runs scrypt for every candidate key
+	if include != "" && *bip38Flag {
+		log.Fatalln("--bip38 can not be used with --include")
+	}
+	// These addresses are fixed by their flags, a vanity search would never
+	// find another one
+	if include != "" {
+		switch network.(type) {
+		case *multisig, *musig:
+			log.Fatalf("--include can not be used with %s, its address is fixed by its keys\n", network.Name())
+		case *silent, *paymentCode, *electrum:
+			if customMnemonic != "" {
+				log.Fatalf("--include can not be used with %s and --custom_mnemonic\n", network.Name())
+			}
+		}
+	}
 
 	// If we just want to generate a keypair without include logic
 	if include == "" {
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+type multisig struct{}
+
+func (ms multisig) Name() string {
+	return "Multisig"
+}
+
+// cosigner is an account xpub with its optional key origin
+type cosigner struct {
+	origin string // e.g. [d34db33f/48'/0'/0'/2']
+	key    *hdkeychain.ExtendedKey
+}
+
+// parseCosigner parses an xpub given as "[fingerprint/path]xpub" or "xpub".
+// SLIP-132 versions (Ypub, Zpub, ...) are converted to plain xpub.
+func parseCosigner(s string) (*cosigner, error) {
+	c := new(cosigner)
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid key origin in %q", s)
+		}
+		c.origin = strings.ReplaceAll(s[:end+1], "h", "'")
+		s = s[end+1:]
+	}
+
+	key, err := hdkeychain.NewKeyFromString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpub %q: %v", s, err)
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("expected an extended public key, got a private one")
+	}
+	c.key, err = key.CloneWithVersion(chaincfg.MainNetParams.HDPublicKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GenerateKeys derives the sortedmulti addresses of the --xpub cosigners
+// at receive index --index. The P2WSH address is the public key, the
+// other script types, the scripts and the descriptors are details.
+func (ms multisig) GenerateKeys() (*KeyPair, error) {
+	n := len(xpubFlag)
+	m := *thresholdFlag
+	if n == 0 {
+		return nil, errors.New("no cosigner xpubs given, use --xpub")
+	}
+	if m < 1 || m > n {
+		return nil, fmt.Errorf("invalid threshold %d for %d cosigners", m, n)
+	}
+	if n > 20 {
+		return nil, fmt.Errorf("too many cosigners: %d (max 20)", n)
+	}
+	if *indexFlag < 0 || *indexFlag >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("invalid index %d", *indexFlag)
+	}
+	params := &chaincfg.MainNetParams
+
+	var cosigners []*cosigner
+	var pubKeys []*btcutil.AddressPubKey
+	for _, x := range xpubFlag {
+		c, err := parseCosigner(x)
+		if err != nil {
+			return nil, err
+		}
+		cosigners = append(cosigners, c)
+
+		// Derive the receive key <xpub>/0/<index>
+		child, err := c.key.Derive(0)
+		if err != nil {
+			return nil, err
+		}
+		child, err = child.Derive(uint32(*indexFlag))
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		addrPubKey, err := btcutil.NewAddressPubKey(pubKey.SerializeCompressed(), params)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, addrPubKey)
+	}
+
+	// sortedmulti orders the keys lexicographically (BIP-67)
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i].ScriptAddress(), pubKeys[j].ScriptAddress()) < 0
+	})
+	script, err := txscript.MultiSigScript(pubKeys, m)
+	if err != nil {
+		return nil, err
+	}
+
+	// P2WSH
+	scriptHash := sha256.Sum256(script)
+	wshAddr, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:], params)
+	if err != nil {
+		return nil, err
+	}
+
+	// P2SH-P2WSH, the redeem script is the P2WSH witness program
+	redeemScript, err := txscript.PayToAddrScript(wshAddr)
+	if err != nil {
+		return nil, err
+	}
+	shWshAddr, err := btcutil.NewAddressScriptHash(redeemScript, params)
+	if err != nil {
+		return nil, err
+	}
+
+	details := []detail{
+		{"p2sh-p2wsh", shWshAddr.EncodeAddress()},
+	}
+
+	// Bare P2SH is limited to 15 keys by the 520 byte redeem script limit
+	if n <= 15 {
+		shAddr, err := btcutil.NewAddressScriptHash(script, params)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, detail{"p2sh", shAddr.EncodeAddress()})
+	}
+	details = append(details, detail{"witness", hex.EncodeToString(script)})
+
+	// Descriptors for the whole receive chain
+	keys := make([]string, len(cosigners))
+	for i, c := range cosigners {
+		keys[i] = c.origin + c.key.String() + "/0/*"
+	}
+	sortedMulti := fmt.Sprintf("sortedmulti(%d,%s)", m, strings.Join(keys, ","))
+	details = append(details,
+		detail{"descriptor", addDescriptorChecksum("wsh(" + sortedMulti + ")")},
+		detail{"descriptor", addDescriptorChecksum("sh(wsh(" + sortedMulti + "))")},
+	)
+	if n <= 15 {
+		details = append(details, detail{"descriptor", addDescriptorChecksum("sh(" + sortedMulti + ")")})
+	}
+
+	return &KeyPair{
+		network:        fmt.Sprintf("multisig %d-of-%d", m, n),
+		public:         wshAddr.EncodeAddress(),
+		derivationPath: fmt.Sprintf("0/%d", *indexFlag),
+		details:        details,
+	}, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+)
+
+type musig struct{}
+
+func (mu musig) Name() string {
+	return "MuSig2"
+}
+
+// parseSigner returns the private key of a local party, given either as a
+// WIF or as a mnemonic with an optional derivation path ("words|path").
+// The taproot derivation path is used when no path is given.
+func parseSigner(signer string) (*btcec.PrivateKey, error) {
+	if !strings.Contains(strings.TrimSpace(signer), " ") {
+		wif, err := btcutil.DecodeWIF(signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode WIF private key: %v", err)
+		}
+		return wif.PrivKey, nil
+	}
+
+	btc := btcMap["taproot"]
+	mnemonic, path, found := strings.Cut(signer, "|")
+	if !found {
+		path = btc.derivationPath
+	}
+	mnemonic = strings.TrimSpace(mnemonic)
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("invalid mnemonic phrase")
+	}
+
+	masterKey, err := bip32.NewMasterKey(bip39.NewSeed(mnemonic, ""))
+	if err != nil {
+		return nil, err
+	}
+	childKey, err := btc.deriveChildKeyFromMaster(masterKey, strings.TrimSpace(path))
+	if err != nil {
+		return nil, err
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(childKey.Key)
+	return privKey, nil
+}
+
+// GenerateKeys aggregates the --pubkey and --signer keys into a BIP-327
+// MuSig2 key and returns its BIP-86 key-path taproot address. When a
+// --message hash is given and every party is a local --signer, the
+// message is also signed in a local signing session.
+func (mu musig) GenerateKeys() (*KeyPair, error) {
+	var pubKeys []*btcec.PublicKey
+	var privKeys []*btcec.PrivateKey
+
+	for _, p := range pubKeyFlag {
+		keyBytes, err := hex.DecodeString(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %v", p, err)
+		}
+		pubKey, err := btcec.ParsePubKey(keyBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %v", p, err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	for _, s := range signerFlag {
+		privKey, err := parseSigner(s)
+		if err != nil {
+			return nil, err
+		}
+		privKeys = append(privKeys, privKey)
+		pubKeys = append(pubKeys, privKey.PubKey())
+	}
+	if len(pubKeys) < 2 {
+		return nil, errors.New("musig2 needs at least two keys, use --pubkey or --signer")
+	}
+
+	// Aggregate the sorted keys and apply the BIP-86 taproot tweak
+	aggKey, _, _, err := musig2.AggregateKeys(pubKeys, true, musig2.WithBIP86KeyTweak())
+	if err != nil {
+		return nil, err
+	}
+	addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(aggKey.FinalKey), btcMap["taproot"].getParams())
+	if err != nil {
+		return nil, err
+	}
+
+	var details []detail
+	for i, pubKey := range pubKeys {
+		details = append(details, detail{fmt.Sprintf("key %d", i), hex.EncodeToString(pubKey.SerializeCompressed())})
+	}
+	details = append(details,
+		detail{"aggregate", hex.EncodeToString(schnorr.SerializePubKey(aggKey.PreTweakedKey))},
+		detail{"output key", hex.EncodeToString(schnorr.SerializePubKey(aggKey.FinalKey))},
+	)
+
+	if *messageFlag != "" {
+		if len(privKeys) != len(pubKeys) {
+			return nil, errors.New("signing requires every party as a local --signer")
+		}
+		sigDetails, err := musigSign(privKeys, pubKeys, aggKey.FinalKey)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, sigDetails...)
+	}
+
+	return &KeyPair{
+		network: fmt.Sprintf("musig2 %d-of-%d", len(pubKeys), len(pubKeys)),
+		public:  addr.EncodeAddress(),
+		details: details,
+	}, nil
+}
+
+// musigSign runs both MuSig2 signing rounds for the local parties over the
+// 32-byte --message hash and verifies the combined signature against the
+// tweaked output key.
+func musigSign(privKeys []*btcec.PrivateKey, pubKeys []*btcec.PublicKey, outputKey *btcec.PublicKey) ([]detail, error) {
+	msgBytes, err := hex.DecodeString(*messageFlag)
+	if err != nil || len(msgBytes) != 32 {
+		return nil, errors.New("message must be a 32-byte hash in hex")
+	}
+	var msg [32]byte
+	copy(msg[:], msgBytes)
+
+	var details []detail
+
+	// Round 1: every party generates and shares its nonces
+	nonces := make([]*musig2.Nonces, len(privKeys))
+	pubNonces := make([][musig2.PubNonceSize]byte, len(privKeys))
+	for i, privKey := range privKeys {
+		nonces[i], err = musig2.GenNonces(
+			musig2.WithPublicKey(privKey.PubKey()),
+			musig2.WithNonceSecretKeyAux(privKey),
+			musig2.WithNonceMessageAux(msg),
+		)
+		if err != nil {
+			return nil, err
+		}
+		pubNonces[i] = nonces[i].PubNonce
+		details = append(details, detail{fmt.Sprintf("nonce %d", i), hex.EncodeToString(pubNonces[i][:])})
+	}
+	combinedNonce, err := musig2.AggregateNonces(pubNonces)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round 2: every party creates its partial signature
+	partialSigs := make([]*musig2.PartialSignature, len(privKeys))
+	for i, privKey := range privKeys {
+		partialSigs[i], err = musig2.Sign(nonces[i].SecNonce, privKey, combinedNonce, pubKeys, msg,
+			musig2.WithSortedKeys(), musig2.WithBip86SignTweak())
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := partialSigs[i].Encode(&buf); err != nil {
+			return nil, err
+		}
+		details = append(details, detail{fmt.Sprintf("partial %d", i), hex.EncodeToString(buf.Bytes())})
+	}
+
+	// Combine the partial signatures into one schnorr signature
+	sig := musig2.CombineSigs(partialSigs[0].R, partialSigs,
+		musig2.WithBip86TweakedCombine(msg, pubKeys, true))
+	if !sig.Verify(msg[:], outputKey) {
+		return nil, errors.New("combined signature does not verify")
+	}
+
+	details = append(details,
+		detail{"message", hex.EncodeToString(msg[:])},
+		detail{"signature", hex.EncodeToString(sig.Serialize())},
+	)
+	return details, nil
+}
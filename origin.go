@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/tyler-smith/go-bip32"
+)
+
+// keyOrigin is the BIP-32 origin of an HD-derived key
+type keyOrigin struct {
+	fingerprint string // master key fingerprint
+	parent      string // parent key fingerprint
+	path        string // full derivation path, e.g. m/84'/0'/0'/0/0
+	account     string // account xpub with its key origin
+}
+
+// fingerprint returns the BIP-32 fingerprint of key, the first 4 bytes of
+// the hash160 of its public key
+func fingerprint(key *bip32.Key) string {
+	return hex.EncodeToString(btcutil.Hash160(key.PublicKey().Key)[:4])
+}
+
+// originNotation formats a fingerprint and path as [d34db33f/84'/0'/0']
+func originNotation(fingerprint, path string) string {
+	return "[" + fingerprint + strings.TrimPrefix(path, "m") + "]"
+}
+
+// accountPath returns path up to its last hardened level, the account
+// whose xpub can derive the rest of the path
+func accountPath(path string) string {
+	components := strings.Split(path, "/")
+	last := 0
+	for i, c := range components {
+		if strings.HasSuffix(c, "'") {
+			last = i
+		}
+	}
+	return strings.Join(components[:last+1], "/")
+}
+
+// newKeyOrigin returns the origin of the key derived from masterKey at path
+func newKeyOrigin(masterKey *bip32.Key, path string) (*keyOrigin, error) {
+	btc := btcMap["legacy"]
+	key, err := btc.deriveChildKeyFromMaster(masterKey, path)
+	if err != nil {
+		return nil, err
+	}
+	account := accountPath(path)
+	accountKey, err := btc.deriveChildKeyFromMaster(masterKey, account)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &keyOrigin{
+		fingerprint: fingerprint(masterKey),
+		parent:      hex.EncodeToString(key.FingerPrint),
+		path:        path,
+	}
+	o.account = originNotation(o.fingerprint, account) + accountKey.PublicKey().B58Serialize()
+	return o, nil
+}
+
+// details returns the origin as printable details
+func (o *keyOrigin) details() []detail {
+	details := []detail{{"fingerprint", o.fingerprint}}
+	if o.parent != "" {
+		details = append(details, detail{"parent fp", o.parent})
+	}
+	details = append(details, detail{"key origin", originNotation(o.fingerprint, o.path)})
+	if o.account != "" {
+		details = append(details, detail{"account", o.account})
+	}
+	return details
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// The psbt package only reads version 0 PSBTs. A BIP-370 version 2 PSBT is
+// converted to version 0 for signing and the signed fields are merged back
+// into the original version 2 maps afterwards.
+
+// psbtMagic are the magic bytes starting every PSBT
+var psbtMagic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// BIP-370 key types
+const (
+	psbtGlobalUnsignedTx     = 0x00
+	psbtGlobalTxVersion      = 0x02
+	psbtGlobalFallbackLock   = 0x03
+	psbtGlobalInputCount     = 0x04
+	psbtGlobalOutputCount    = 0x05
+	psbtGlobalTxModifiable   = 0x06
+	psbtGlobalVersion        = 0xfb
+	psbtInPreviousTxid       = 0x0e
+	psbtInOutputIndex        = 0x0f
+	psbtInSequence           = 0x10
+	psbtInRequiredTimeLock   = 0x11
+	psbtInRequiredHeightLock = 0x12
+	psbtOutAmount            = 0x03
+	psbtOutScript            = 0x04
+)
+
+// psbtEntry is a raw key-value pair of a PSBT map
+type psbtEntry struct {
+	key   []byte
+	value []byte
+}
+
+// psbtMap is a raw PSBT map
+type psbtMap []psbtEntry
+
+// get returns the value of the key type without key data
+func (m psbtMap) get(keyType byte) ([]byte, bool) {
+	for _, e := range m {
+		if len(e.key) == 1 && e.key[0] == keyType {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// filter returns the entries whose key type is (keep) or is not (!keep)
+// one of keyTypes
+func (m psbtMap) filter(keep bool, keyTypes ...byte) psbtMap {
+	var result psbtMap
+	for _, e := range m {
+		found := false
+		for _, t := range keyTypes {
+			if e.key[0] == t {
+				found = true
+			}
+		}
+		if found == keep {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// psbtMaps is a PSBT split into its raw maps
+type psbtMaps struct {
+	global  psbtMap
+	inputs  []psbtMap
+	outputs []psbtMap
+}
+
+// readPSBTMap reads one map up to its 0x00 separator
+func readPSBTMap(r io.Reader) (psbtMap, error) {
+	var m psbtMap
+	for {
+		key, err := wire.ReadVarBytes(r, 0, wire.MaxMessagePayload, "PSBT key")
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			return m, nil
+		}
+		value, err := wire.ReadVarBytes(r, 0, wire.MaxMessagePayload, "PSBT value")
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, psbtEntry{key, value})
+	}
+}
+
+// psbtVersion returns the PSBT_GLOBAL_VERSION of a raw PSBT
+func psbtVersion(raw []byte) (uint32, error) {
+	if !bytes.HasPrefix(raw, psbtMagic) {
+		return 0, errors.New("invalid PSBT magic bytes")
+	}
+	global, err := readPSBTMap(bytes.NewReader(raw[len(psbtMagic):]))
+	if err != nil {
+		return 0, err
+	}
+	version, ok := global.get(psbtGlobalVersion)
+	if !ok {
+		return 0, nil
+	}
+	if len(version) != 4 {
+		return 0, errors.New("invalid PSBT version")
+	}
+	return binary.LittleEndian.Uint32(version), nil
+}
+
+// readPSBTMaps splits a raw PSBT into its maps. The input and output
+// counts are read from the unsigned transaction (version 0) or the
+// global count fields (version 2).
+func readPSBTMaps(raw []byte) (*psbtMaps, error) {
+	if !bytes.HasPrefix(raw, psbtMagic) {
+		return nil, errors.New("invalid PSBT magic bytes")
+	}
+	r := bytes.NewReader(raw[len(psbtMagic):])
+
+	global, err := readPSBTMap(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs, outputs uint64
+	if unsignedTx, ok := global.get(psbtGlobalUnsignedTx); ok {
+		tx := wire.NewMsgTx(0)
+		if err := tx.DeserializeNoWitness(bytes.NewReader(unsignedTx)); err != nil {
+			return nil, err
+		}
+		inputs, outputs = uint64(len(tx.TxIn)), uint64(len(tx.TxOut))
+	} else {
+		inputCount, ok1 := global.get(psbtGlobalInputCount)
+		outputCount, ok2 := global.get(psbtGlobalOutputCount)
+		if !ok1 || !ok2 {
+			return nil, errors.New("PSBT has no input or output count")
+		}
+		if inputs, err = wire.ReadVarInt(bytes.NewReader(inputCount), 0); err != nil {
+			return nil, err
+		}
+		if outputs, err = wire.ReadVarInt(bytes.NewReader(outputCount), 0); err != nil {
+			return nil, err
+		}
+	}
+	if inputs > uint64(r.Len()) || outputs > uint64(r.Len()) {
+		return nil, errors.New("invalid PSBT input or output count")
+	}
+
+	maps := &psbtMaps{global: global}
+	for i := uint64(0); i < inputs; i++ {
+		m, err := readPSBTMap(r)
+		if err != nil {
+			return nil, err
+		}
+		maps.inputs = append(maps.inputs, m)
+	}
+	for i := uint64(0); i < outputs; i++ {
+		m, err := readPSBTMap(r)
+		if err != nil {
+			return nil, err
+		}
+		maps.outputs = append(maps.outputs, m)
+	}
+	return maps, nil
+}
+
+// serialize writes the maps as a raw PSBT, sorting each map by key
+func (p *psbtMaps) serialize() []byte {
+	var buf bytes.Buffer
+	buf.Write(psbtMagic)
+	writeMap := func(m psbtMap) {
+		sorted := append(psbtMap(nil), m...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return bytes.Compare(sorted[i].key, sorted[j].key) < 0
+		})
+		for _, e := range sorted {
+			_ = wire.WriteVarBytes(&buf, 0, e.key)
+			_ = wire.WriteVarBytes(&buf, 0, e.value)
+		}
+		buf.WriteByte(0x00)
+	}
+	writeMap(p.global)
+	for _, m := range p.inputs {
+		writeMap(m)
+	}
+	for _, m := range p.outputs {
+		writeMap(m)
+	}
+	return buf.Bytes()
+}
+
+// unsignedTx builds the transaction described by the fields of a
+// version 2 PSBT, including the BIP-370 lock time selection
+func (p *psbtMaps) unsignedTx() (*wire.MsgTx, error) {
+	version, ok := p.global.get(psbtGlobalTxVersion)
+	if !ok || len(version) != 4 {
+		return nil, errors.New("PSBT has no transaction version")
+	}
+	tx := wire.NewMsgTx(int32(binary.LittleEndian.Uint32(version)))
+
+	// The lock time type is the one supported by every input that
+	// requires a lock time, preferring height when both are
+	var timeLock, heightLock uint32
+	hasLock, allTime, allHeight := false, true, true
+	for i, in := range p.inputs {
+		txid, ok1 := in.get(psbtInPreviousTxid)
+		index, ok2 := in.get(psbtInOutputIndex)
+		if !ok1 || !ok2 || len(txid) != chainhash.HashSize || len(index) != 4 {
+			return nil, errors.New("PSBT input has no previous output")
+		}
+		hash, _ := chainhash.NewHash(txid)
+		txIn := wire.NewTxIn(wire.NewOutPoint(hash, binary.LittleEndian.Uint32(index)), nil, nil)
+		if sequence, ok := in.get(psbtInSequence); ok && len(sequence) == 4 {
+			txIn.Sequence = binary.LittleEndian.Uint32(sequence)
+		}
+		tx.AddTxIn(txIn)
+
+		t, okTime := in.get(psbtInRequiredTimeLock)
+		h, okHeight := in.get(psbtInRequiredHeightLock)
+		if (okTime && len(t) != 4) || (okHeight && len(h) != 4) {
+			return nil, fmt.Errorf("invalid lock time in PSBT input %d", i)
+		}
+		if okTime {
+			timeLock = max(timeLock, binary.LittleEndian.Uint32(t))
+		}
+		if okHeight {
+			heightLock = max(heightLock, binary.LittleEndian.Uint32(h))
+		}
+		if okTime || okHeight {
+			hasLock = true
+			allTime = allTime && okTime
+			allHeight = allHeight && okHeight
+		}
+	}
+
+	switch {
+	case !hasLock:
+		if fallback, ok := p.global.get(psbtGlobalFallbackLock); ok && len(fallback) == 4 {
+			tx.LockTime = binary.LittleEndian.Uint32(fallback)
+		}
+	case allHeight:
+		tx.LockTime = heightLock
+	case allTime:
+		tx.LockTime = timeLock
+	default:
+		return nil, errors.New("PSBT inputs have conflicting lock times")
+	}
+
+	for _, out := range p.outputs {
+		amount, ok1 := out.get(psbtOutAmount)
+		script, ok2 := out.get(psbtOutScript)
+		if !ok1 || !ok2 || len(amount) != 8 {
+			return nil, errors.New("PSBT output has no amount or script")
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(binary.LittleEndian.Uint64(amount)), script))
+	}
+	return tx, nil
+}
+
+// toV0 converts version 2 maps to a raw version 0 PSBT
+func (p *psbtMaps) toV0() ([]byte, error) {
+	tx, err := p.unsignedTx()
+	if err != nil {
+		return nil, err
+	}
+	var txBuf bytes.Buffer
+	if err := tx.SerializeNoWitness(&txBuf); err != nil {
+		return nil, err
+	}
+
+	v0 := &psbtMaps{
+		global: append(psbtMap{{[]byte{psbtGlobalUnsignedTx}, txBuf.Bytes()}},
+			p.global.filter(false, psbtGlobalTxVersion, psbtGlobalFallbackLock, psbtGlobalInputCount,
+				psbtGlobalOutputCount, psbtGlobalTxModifiable, psbtGlobalVersion)...),
+	}
+	for _, in := range p.inputs {
+		v0.inputs = append(v0.inputs, in.filter(false, psbtInPreviousTxid, psbtInOutputIndex,
+			psbtInSequence, psbtInRequiredTimeLock, psbtInRequiredHeightLock))
+	}
+	for _, out := range p.outputs {
+		v0.outputs = append(v0.outputs, out.filter(false, psbtOutAmount, psbtOutScript))
+	}
+	return v0.serialize(), nil
+}
+
+// mergeV0 merges a signed raw version 0 PSBT created by toV0 back into
+// the version 2 maps, clearing the modifiable flags the new signatures
+// commit to.
+func (p *psbtMaps) mergeV0(raw []byte, sigHashes []byte) ([]byte, error) {
+	v0, err := readPSBTMaps(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	v2 := &psbtMaps{
+		global: append(v0.global.filter(false, psbtGlobalUnsignedTx),
+			p.global.filter(true, psbtGlobalTxVersion, psbtGlobalFallbackLock, psbtGlobalInputCount,
+				psbtGlobalOutputCount, psbtGlobalTxModifiable, psbtGlobalVersion)...),
+	}
+	for i, in := range v0.inputs {
+		v2.inputs = append(v2.inputs, append(in, p.inputs[i].filter(true, psbtInPreviousTxid,
+			psbtInOutputIndex, psbtInSequence, psbtInRequiredTimeLock, psbtInRequiredHeightLock)...))
+	}
+	for i, out := range v0.outputs {
+		v2.outputs = append(v2.outputs, append(out, p.outputs[i].filter(true, psbtOutAmount, psbtOutScript)...))
+	}
+
+	// Bit 0: inputs modifiable, bit 1: outputs modifiable,
+	// bit 2: has SIGHASH_SINGLE
+	for i, e := range v2.global {
+		if len(e.key) != 1 || e.key[0] != psbtGlobalTxModifiable || len(e.value) != 1 {
+			continue
+		}
+		flags := e.value[0]
+		for _, sigHash := range sigHashes {
+			if sigHash&0x80 == 0 {
+				flags &^= 0x01
+			}
+			switch sigHash & 0x1f {
+			case 0x00, 0x01:
+				flags &^= 0x02
+			case 0x03:
+				flags |= 0x04
+			}
+		}
+		v2.global[i].value = []byte{flags}
+	}
+
+	return v2.serialize(), nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"filippo.io/edwards25519"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// tronVersion is the version byte of Tron addresses
+const tronVersion = 0x41
+
+// decodePublicKey decodes a hex or base58 public key. 33 and 65 bytes are
+// a compressed or uncompressed secp256k1 key, 64 bytes an uncompressed key
+// without the 04 prefix as HSMs export it. 32 bytes are an ed25519 key, or
+// with --xonly an x-only secp256k1 (taproot) key. format is the secp256k1
+// key format: compressed, uncompressed or x-only.
+func decodePublicKey(s string) (secp *btcec.PublicKey, format string, ed25519 []byte, err error) {
+	// Base58 keys can also be valid hex, of another length
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || (len(b) != 32 && len(b) != 33 && len(b) != 64 && len(b) != 65) {
+		if decoded := base58.Decode(s); len(decoded) > 0 {
+			b = decoded
+		} else if err != nil {
+			return nil, "", nil, fmt.Errorf("invalid public key %q, use hex or base58", s)
+		}
+	}
+
+	switch len(b) {
+	case 32:
+		if *xOnlyFlag {
+			if secp, err = schnorr.ParsePubKey(b); err != nil {
+				return nil, "", nil, fmt.Errorf("invalid x-only public key %q: %v", s, err)
+			}
+			return secp, "x-only", nil, nil
+		}
+		if _, err := new(edwards25519.Point).SetBytes(b); err != nil {
+			return nil, "", nil, fmt.Errorf("invalid ed25519 public key %q, use --xonly for a taproot key", s)
+		}
+		return nil, "", b, nil
+	case 64:
+		b = append([]byte{0x04}, b...)
+	case 33, 65:
+	default:
+		return nil, "", nil, fmt.Errorf("invalid public key %q of %d bytes", s, len(b))
+	}
+	secp, err = btcec.ParsePubKey(b)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("invalid secp256k1 public key %q: %v", s, err)
+	}
+	if len(b) == 33 {
+		return secp, "compressed", nil, nil
+	}
+	return secp, "uncompressed", nil, nil
+}
+
+// ethPubKeyAddress returns the Ethereum address of a secp256k1 public key
+func ethPubKeyAddress(pubKey *btcec.PublicKey) common.Address {
+	return common.BytesToAddress(crypto.Keccak256(pubKey.SerializeUncompressed()[1:])[12:])
+}
+
+// pubKeyKeyPairs returns the addresses of a secp256k1 public key of format
+// on every network: the Bitcoin address types and the account networks. An
+// x-only key only has a taproot address, its other addresses depend on the
+// unknown y parity.
+func pubKeyKeyPairs(pubKey *btcec.PublicKey, format string) ([]*KeyPair, error) {
+	if format == "x-only" {
+		taproot := btcMap["taproot"]
+		address, err := taproot.pubKeyAddress(pubKey, true)
+		if err != nil {
+			return nil, err
+		}
+		return []*KeyPair{{network: taproot.name, public: address.EncodeAddress(), keyFormat: format}}, nil
+	}
+
+	// The compressed rows of an uncompressed input say so, wallets of the
+	// uncompressed key do not derive them
+	keyFormat := "compressed"
+	if format == "uncompressed" {
+		keyFormat = "compressed (input was uncompressed)"
+	}
+	var keyPairs []*KeyPair
+	for _, name := range btcOrder {
+		btc := btcMap[name]
+		address, err := btc.pubKeyAddress(pubKey, true)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, &KeyPair{network: btc.name, public: address.EncodeAddress(), keyFormat: keyFormat})
+	}
+	// Legacy addresses of uncompressed keys hash the uncompressed form
+	if format == "uncompressed" {
+		address, err := btcMap["legacy"].pubKeyAddress(pubKey, false)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, &KeyPair{network: "bitcoin legacy (uncompressed)", public: address.EncodeAddress(), keyFormat: "uncompressed"})
+	}
+	return append(keyPairs, accountKeyPairs(pubKey)...), nil
+}
+
+// accountKeyPairs returns the addresses of a secp256k1 public key on the
+// account based networks: Ethereum and the EVM chains (Rootstock has its
+// own checksum), Tron and Cosmos
+func accountKeyPairs(pubKey *btcec.PublicKey) []*KeyPair {
+	address := ethPubKeyAddress(pubKey)
+	rsk := evmChains["rsk"]
+
+	// Cosmos hashes the compressed key like Bitcoin, the bech32 encoding
+	// of a 20-byte hash can not fail
+	cosmos, _ := bech32.EncodeFromBase256("cosmos", btcutil.Hash160(pubKey.SerializeCompressed()))
+	return []*KeyPair{
+		{network: "ethereum (and EVM chains)", public: checksumAddress(address, *chainIDFlag)},
+		{network: rsk.name, public: checksumAddress(address, rsk.chainID)},
+		{network: "tron", public: base58.CheckEncode(address[:], tronVersion)},
+		{network: "cosmos", public: cosmos},
+	}
+}
+
+// PubKeyToAddress prints the addresses of every --pubkey on the networks of
+// its curve
+func PubKeyToAddress() error {
+	if len(pubKeyFlag) == 0 {
+		return errors.New("no public key given, use --pubkey")
+	}
+	for i, s := range pubKeyFlag {
+		secp, format, ed25519, err := decodePublicKey(s)
+		if err != nil {
+			return err
+		}
+		keyPairs := []*KeyPair{{network: "solana", public: base58.Encode(ed25519), keyFormat: "ed25519"}}
+		if secp != nil {
+			if keyPairs, err = pubKeyKeyPairs(secp, format); err != nil {
+				return err
+			}
+		}
+		if i > 0 {
+			fmt.Println("")
+		}
+		PrintAll(keyPairs)
+	}
+	return nil
+}
+
+// EcRecover recovers the public key and Ethereum address that signed
+// --message or --typed_data with --signature
+func EcRecover() (*KeyPair, error) {
+	pubKey, details, err := ethRecover()
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		network: "ethereum",
+		public:  checksumAddress(crypto.PubkeyToAddress(*pubKey), *chainIDFlag),
+		details: append(details,
+			detail{"pubkey", hexutil.Encode(crypto.CompressPubkey(pubKey))},
+			detail{"uncompressed", hexutil.Encode(crypto.FromECDSAPub(pubKey))},
+		),
+	}, nil
+}
+
+// CrossChain prints the addresses of the secp256k1 key --custom_private,
+// WIF or hex, on every network
+func CrossChain() error {
+	if customPrivate == "" {
+		return errors.New("no key given, use --custom_private")
+	}
+	wif, err := btcutil.DecodeWIF(customPrivate)
+	if err != nil {
+		// Hex keys use compressed public keys
+		b, hexErr := hex.DecodeString(strings.TrimPrefix(customPrivate, "0x"))
+		if hexErr != nil || len(b) != 32 {
+			return errors.New("invalid private key, use WIF or 32 bytes of hex")
+		}
+		privKey, _ := btcec.PrivKeyFromBytes(b)
+		if privKey.Key.IsZero() {
+			return errors.New("invalid private key")
+		}
+		if wif, err = btcutil.NewWIF(privKey, &chaincfg.MainNetParams, true); err != nil {
+			return err
+		}
+	}
+
+	keyPairs, err := allKeyPairs(wif)
+	if err != nil {
+		return err
+	}
+	PrintAll(append(keyPairs, accountKeyPairs(wif.PrivKey.PubKey())...))
+	fmt.Printf("%-29s %-12s %s\n", "hex", "private", hex.EncodeToString(wif.PrivKey.Serialize()))
+	return nil
+}
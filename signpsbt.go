@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// psbtSigner holds the key PSBT inputs are signed with: an extended
+// private key (from a mnemonic or an xprv) or a single WIF key
+type psbtSigner struct {
+	extKey      *hdkeychain.ExtendedKey
+	fingerprint uint32
+	wif         *btcutil.WIF
+}
+
+// newPSBTSigner creates the signer from --custom_mnemonic or
+// --custom_private, which may hold an xprv or a WIF
+func newPSBTSigner() (*psbtSigner, error) {
+	s := new(psbtSigner)
+	var err error
+
+	switch {
+	case customMnemonic != "":
+		if !bip39.IsMnemonicValid(customMnemonic) {
+			return nil, errors.New("invalid mnemonic phrase")
+		}
+		s.extKey, err = hdkeychain.NewMaster(bip39.NewSeed(customMnemonic, ""), &chaincfg.MainNetParams)
+		if err != nil {
+			return nil, err
+		}
+	case customPrivate != "":
+		if extKey, err := hdkeychain.NewKeyFromString(customPrivate); err == nil {
+			if !extKey.IsPrivate() {
+				return nil, errors.New("expected an extended private key, got a public one")
+			}
+			s.extKey = extKey
+		} else if s.wif, err = btcutil.DecodeWIF(customPrivate); err != nil {
+			return nil, fmt.Errorf("failed to decode private key: %v", err)
+		}
+	default:
+		return nil, errors.New("no key given, use --custom_private or --custom_mnemonic")
+	}
+
+	if s.extKey != nil && s.extKey.Depth() == 0 {
+		pubKey, err := s.extKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		s.fingerprint = binary.LittleEndian.Uint32(btcutil.Hash160(pubKey.SerializeCompressed())[:4])
+	}
+	return s, nil
+}
+
+// keyFor returns the private key of pubKey (33 bytes, or 32 bytes x-only)
+// derived at path from the master key with fingerprint, or nil when the
+// signer does not hold that key. A non-master xprv at depth d is assumed
+// to be the key at the first d path elements.
+func (s *psbtSigner) keyFor(pubKey []byte, fingerprint uint32, path []uint32) *btcec.PrivateKey {
+	var privKey *btcec.PrivateKey
+	if s.wif != nil {
+		privKey = s.wif.PrivKey
+	} else {
+		depth := int(s.extKey.Depth())
+		if (depth == 0 && fingerprint != s.fingerprint) || depth > len(path) {
+			return nil
+		}
+		key := s.extKey
+		for _, index := range path[depth:] {
+			var err error
+			if key, err = key.Derive(index); err != nil {
+				return nil
+			}
+		}
+		var err error
+		if privKey, err = key.ECPrivKey(); err != nil {
+			return nil
+		}
+	}
+
+	pub := privKey.PubKey()
+	switch {
+	case len(pubKey) == schnorr.PubKeyBytesLen && bytes.Equal(pubKey, schnorr.SerializePubKey(pub)):
+		return privKey
+	case bytes.Equal(pubKey, pub.SerializeCompressed()), bytes.Equal(pubKey, pub.SerializeUncompressed()):
+		return privKey
+	}
+	return nil
+}
+
+// candidates returns the public keys of an input the signer can sign for,
+// mapped to their private keys
+func (s *psbtSigner) candidates(in *psbt.PInput) map[string]*btcec.PrivateKey {
+	keys := make(map[string]*btcec.PrivateKey)
+	for _, d := range in.Bip32Derivation {
+		if privKey := s.keyFor(d.PubKey, d.MasterKeyFingerprint, d.Bip32Path); privKey != nil {
+			keys[string(d.PubKey)] = privKey
+		}
+	}
+	for _, d := range in.TaprootBip32Derivation {
+		// Only key-path spends are signed
+		if len(d.LeafHashes) != 0 {
+			continue
+		}
+		if privKey := s.keyFor(d.XOnlyPubKey, d.MasterKeyFingerprint, d.Bip32Path); privKey != nil {
+			keys[string(d.XOnlyPubKey)] = privKey
+		}
+	}
+	if s.wif != nil {
+		pub := s.wif.PrivKey.PubKey()
+		keys[string(s.wif.SerializePubKey())] = s.wif.PrivKey
+		keys[string(pub.SerializeCompressed())] = s.wif.PrivKey
+		keys[string(schnorr.SerializePubKey(pub))] = s.wif.PrivKey
+	}
+	return keys
+}
+
+// prevOutput returns the output spent by input idx of the packet
+func prevOutput(packet *psbt.Packet, idx int) (*wire.TxOut, error) {
+	in := packet.Inputs[idx]
+	outPoint := packet.UnsignedTx.TxIn[idx].PreviousOutPoint
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, nil
+	}
+	if in.NonWitnessUtxo != nil {
+		if in.NonWitnessUtxo.TxHash() != outPoint.Hash || int(outPoint.Index) >= len(in.NonWitnessUtxo.TxOut) {
+			return nil, fmt.Errorf("non-witness utxo of input %d does not match its outpoint", idx)
+		}
+		return in.NonWitnessUtxo.TxOut[outPoint.Index], nil
+	}
+	return nil, fmt.Errorf("input %d has no utxo", idx)
+}
+
+// signPSBTInput signs input idx of the packet with any matching key of
+// the signer. It returns the script type signed for, or "" when no key
+// matched.
+func (s *psbtSigner) signPSBTInput(packet *psbt.Packet, idx int, fetcher txscript.PrevOutputFetcher) (string, error) {
+	in := &packet.Inputs[idx]
+	if len(in.FinalScriptSig) != 0 || len(in.FinalScriptWitness) != 0 {
+		return "", nil
+	}
+	prevOut, err := prevOutput(packet, idx)
+	if err != nil {
+		return "", err
+	}
+	tx := packet.UnsignedTx
+	pkScript := prevOut.PkScript
+	hashType := in.SighashType
+	if hashType == 0 {
+		hashType = txscript.SigHashAll
+	}
+
+	for pubKeyStr, privKey := range s.candidates(in) {
+		pubKey := []byte(pubKeyStr)
+		switch txscript.GetScriptClass(pkScript) {
+		case txscript.PubKeyHashTy:
+			if len(pubKey) == schnorr.PubKeyBytesLen || !bytes.Equal(pkScript[3:23], btcutil.Hash160(pubKey)) {
+				continue
+			}
+			sig, err := txscript.RawTxInSignature(tx, idx, pkScript, hashType, privKey)
+			if err != nil {
+				return "", err
+			}
+			in.PartialSigs = append(in.PartialSigs, &psbt.PartialSig{PubKey: pubKey, Signature: sig})
+			return "p2pkh", nil
+
+		case txscript.WitnessV0PubKeyHashTy:
+			if len(pubKey) != btcec.PubKeyBytesLenCompressed || !bytes.Equal(pkScript[2:22], btcutil.Hash160(pubKey)) {
+				continue
+			}
+			sig, err := txscript.RawTxInWitnessSignature(tx, txscript.NewTxSigHashes(tx, fetcher), idx,
+				prevOut.Value, pkScript, hashType, privKey)
+			if err != nil {
+				return "", err
+			}
+			in.PartialSigs = append(in.PartialSigs, &psbt.PartialSig{PubKey: pubKey, Signature: sig})
+			return "p2wpkh", nil
+
+		case txscript.ScriptHashTy:
+			if len(pubKey) != btcec.PubKeyBytesLenCompressed {
+				continue
+			}
+			redeemScript, err := nestedWitnessScript(privKey.PubKey())
+			if err != nil {
+				return "", err
+			}
+			if !bytes.Equal(pkScript[2:22], btcutil.Hash160(redeemScript)) {
+				continue
+			}
+			sig, err := txscript.RawTxInWitnessSignature(tx, txscript.NewTxSigHashes(tx, fetcher), idx,
+				prevOut.Value, redeemScript, hashType, privKey)
+			if err != nil {
+				return "", err
+			}
+			in.RedeemScript = redeemScript
+			in.PartialSigs = append(in.PartialSigs, &psbt.PartialSig{PubKey: pubKey, Signature: sig})
+			return "p2sh-p2wpkh", nil
+
+		case txscript.WitnessV1TaprootTy:
+			if len(pubKey) != schnorr.PubKeyBytesLen {
+				continue
+			}
+			outputKey := txscript.ComputeTaprootOutputKey(privKey.PubKey(), in.TaprootMerkleRoot)
+			if !bytes.Equal(pkScript[2:34], schnorr.SerializePubKey(outputKey)) {
+				continue
+			}
+			if in.SighashType == 0 {
+				hashType = txscript.SigHashDefault
+			}
+			sig, err := txscript.RawTxInTaprootSignature(tx, txscript.NewTxSigHashes(tx, fetcher), idx,
+				prevOut.Value, pkScript, in.TaprootMerkleRoot, hashType, privKey)
+			if err != nil {
+				return "", err
+			}
+			in.TaprootKeySpendSig = sig
+			return "p2tr", nil
+
+		default:
+			return "", fmt.Errorf("unsupported script type for input %d", idx)
+		}
+	}
+	return "", nil
+}
+
+// readPSBT reads --psbt, a file with a binary or base64 PSBT or a base64
+// string. It reports whether the PSBT was binary.
+func readPSBT(arg string) ([]byte, bool, error) {
+	data := []byte(arg)
+	if fileData, err := os.ReadFile(arg); err == nil {
+		data = fileData
+	}
+	if bytes.HasPrefix(data, psbtMagic) {
+		return data, true, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid PSBT: %v", err)
+	}
+	return raw, false, nil
+}
+
+// SignPSBT signs every input of --psbt whose key the signer holds and
+// writes the updated PSBT to --out, or prints it as base64. Version 0
+// and version 2 PSBTs are supported.
+func SignPSBT() error {
+	if *psbtFlag == "" {
+		return errors.New("no PSBT given, use --psbt")
+	}
+	signer, err := newPSBTSigner()
+	if err != nil {
+		return err
+	}
+	raw, binaryFormat, err := readPSBT(*psbtFlag)
+	if err != nil {
+		return err
+	}
+
+	// Convert a version 2 PSBT to version 0 for the psbt package
+	version, err := psbtVersion(raw)
+	if err != nil {
+		return err
+	}
+	var v2 *psbtMaps
+	switch version {
+	case 0:
+	case 2:
+		if v2, err = readPSBTMaps(raw); err != nil {
+			return err
+		}
+		if raw, err = v2.toV0(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported PSBT version %d", version)
+	}
+
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(raw), false)
+	if err != nil {
+		return err
+	}
+
+	// Every known previous output, taproot signatures commit to all
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, txIn := range packet.UnsignedTx.TxIn {
+		if prevOut, err := prevOutput(packet, i); err == nil {
+			fetcher.AddPrevOut(txIn.PreviousOutPoint, prevOut)
+		}
+	}
+
+	var sigHashes []byte
+	for i := range packet.Inputs {
+		scriptType, err := signer.signPSBTInput(packet, i, fetcher)
+		if err != nil {
+			return err
+		}
+		if scriptType == "" {
+			continue
+		}
+		sigHashes = append(sigHashes, byte(packet.Inputs[i].SighashType))
+		fmt.Printf("%-3s %-12s input %d (%s)\n", "psbt", "signed", i, scriptType)
+	}
+	if len(sigHashes) == 0 {
+		return errors.New("no input matches the given key")
+	}
+
+	var buf bytes.Buffer
+	if err := packet.Serialize(&buf); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	if v2 != nil {
+		if out, err = v2.mergeV0(out, sigHashes); err != nil {
+			return err
+		}
+	}
+
+	if *outFlag == "" {
+		fmt.Printf("%-3s %-12s %s\n", "psbt", "psbt", base64.StdEncoding.EncodeToString(out))
+		return nil
+	}
+	if !binaryFormat {
+		out = []byte(base64.StdEncoding.EncodeToString(out))
+	}
+	if err := os.WriteFile(*outFlag, out, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("%-3s %-12s %s\n", "psbt", "written", *outFlag)
+	return nil
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// BIP-352 derivation paths of the scan and spend keys
+const (
+	silentScanPath  = "m/352'/0'/0'/1'/0"
+	silentSpendPath = "m/352'/0'/0'/0'/0"
+)
+
+// BIP-352 tagged hash tags
+var (
+	silentInputsTag       = []byte("BIP0352/Inputs")
+	silentSharedSecretTag = []byte("BIP0352/SharedSecret")
+	silentLabelTag        = []byte("BIP0352/Label")
+)
+
+type silent struct{}
+
+func (sp silent) Name() string {
+	return "Silent Payments"
+}
+
+// silentKeys derives the scan and spend keys from mnemonic
+func silentKeys(mnemonic string) (scanKey, spendKey *btcec.PrivateKey, err error) {
+	masterKey, err := bip32.NewMasterKey(bip39.NewSeed(mnemonic, ""))
+	if err != nil {
+		return nil, nil, err
+	}
+	btc := btcMap["taproot"]
+	scan, err := btc.deriveChildKeyFromMaster(masterKey, silentScanPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	spend, err := btc.deriveChildKeyFromMaster(masterKey, silentSpendPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	scanKey, _ = btcec.PrivKeyFromBytes(scan.Key)
+	spendKey, _ = btcec.PrivKeyFromBytes(spend.Key)
+	return scanKey, spendKey, nil
+}
+
+// silentAddress encodes the sp1 address of the scan and spend public keys
+func silentAddress(scan, spend *btcec.PublicKey) (string, error) {
+	data := append(scan.SerializeCompressed(), spend.SerializeCompressed()...)
+	converted, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.EncodeM("sp", append([]byte{0}, converted...))
+}
+
+// GenerateKeys derives the silent payment keys from --custom_mnemonic, or
+// from a new mnemonic, and returns the sp1 address
+func (sp silent) GenerateKeys() (*KeyPair, error) {
+	mnemonic := customMnemonic
+	if mnemonic == "" {
+		entropy, err := bip39.NewEntropy(256)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			return nil, err
+		}
+	} else if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("invalid mnemonic phrase")
+	}
+
+	scanKey, spendKey, err := silentKeys(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	address, err := silentAddress(scanKey.PubKey(), spendKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+
+	k := &KeyPair{
+		network: "silent payments",
+		public:  address,
+		details: []detail{
+			{"scan key", hex.EncodeToString(scanKey.Serialize())},
+			{"spend key", hex.EncodeToString(spendKey.Serialize())},
+		},
+	}
+	if *infoFlag || *infoLongFlag {
+		k.mnemonic = mnemonic
+		k.derivationPath = silentScanPath + ", " + silentSpendPath
+		masterKey, err := bip32.NewMasterKey(bip39.NewSeed(mnemonic, ""))
+		if err != nil {
+			return nil, err
+		}
+		if k.origin, err = newKeyOrigin(masterKey, silentSpendPath); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// silentTx is a transaction listed in the --txs file
+type silentTx struct {
+	TxID   string `json:"txid"`
+	Inputs []struct {
+		TxID   string `json:"txid"`
+		Vout   uint32 `json:"vout"`
+		PubKey string `json:"pubkey"` // compressed or x-only
+	} `json:"inputs"`
+	Outputs []string `json:"outputs"` // x-only keys or P2TR scriptPubKeys
+}
+
+// silentPayment is an output detected as paid to the scan and spend keys
+type silentPayment struct {
+	output   string
+	k        uint32
+	label    int // -1 when unlabeled
+	privKey  *btcec.PrivateKey
+	tweakHex string
+}
+
+// silentLabel is the label m and its tweak hash(b_scan || m)
+type silentLabel struct {
+	m     int
+	tweak *btcec.ModNScalar
+}
+
+// silentLabels maps the label points B_m of labels 0..n to their labels.
+// Label 0 is reserved for change.
+func silentLabels(scanKey *btcec.PrivateKey, n int) map[string]silentLabel {
+	labels := make(map[string]silentLabel)
+	for m := 0; m <= n; m++ {
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], uint32(m))
+		tweak := new(btcec.ModNScalar)
+		tweak.SetByteSlice(chainhash.TaggedHash(silentLabelTag, scanKey.Serialize(), index[:])[:])
+		point := scalarBaseMult(tweak)
+		labels[string(point.SerializeCompressed())] = silentLabel{m, tweak}
+	}
+	return labels
+}
+
+// scalarBaseMult returns k*G
+func scalarBaseMult(k *btcec.ModNScalar) *btcec.PublicKey {
+	var result btcec.JacobianPoint
+	btcec.ScalarBaseMultNonConst(k, &result)
+	result.ToAffine()
+	return btcec.NewPublicKey(&result.X, &result.Y)
+}
+
+// addPoints returns a+b, or nil for the point at infinity
+func addPoints(a, b *btcec.PublicKey) *btcec.PublicKey {
+	var aj, bj, result btcec.JacobianPoint
+	a.AsJacobian(&aj)
+	b.AsJacobian(&bj)
+	btcec.AddNonConst(&aj, &bj, &result)
+	if (result.X.IsZero() && result.Y.IsZero()) || result.Z.IsZero() {
+		return nil
+	}
+	result.ToAffine()
+	return btcec.NewPublicKey(&result.X, &result.Y)
+}
+
+// negate returns -p
+func negate(p *btcec.PublicKey) *btcec.PublicKey {
+	var pj btcec.JacobianPoint
+	p.AsJacobian(&pj)
+	pj.Y.Negate(1).Normalize()
+	return btcec.NewPublicKey(&pj.X, &pj.Y)
+}
+
+// scanSilentTx returns the outputs of tx paid to the scan and spend keys
+func scanSilentTx(tx silentTx, scanKey, spendKey *btcec.PrivateKey, labels map[string]silentLabel) ([]silentPayment, error) {
+	if len(tx.Inputs) == 0 {
+		return nil, nil
+	}
+
+	// Sum the input public keys and find the smallest outpoint
+	var sum *btcec.PublicKey
+	var smallest []byte
+	for _, in := range tx.Inputs {
+		keyBytes, err := hex.DecodeString(in.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input public key %q: %v", in.PubKey, err)
+		}
+		var pubKey *btcec.PublicKey
+		if len(keyBytes) == schnorr.PubKeyBytesLen {
+			pubKey, err = schnorr.ParsePubKey(keyBytes)
+		} else {
+			pubKey, err = btcec.ParsePubKey(keyBytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid input public key %q: %v", in.PubKey, err)
+		}
+		if sum == nil {
+			sum = pubKey
+		} else if sum = addPoints(sum, pubKey); sum == nil {
+			return nil, nil
+		}
+
+		hash, err := chainhash.NewHashFromStr(in.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input txid %q: %v", in.TxID, err)
+		}
+		outpoint := make([]byte, 36)
+		copy(outpoint, hash[:])
+		binary.LittleEndian.PutUint32(outpoint[32:], in.Vout)
+		if smallest == nil || bytes.Compare(outpoint, smallest) < 0 {
+			smallest = outpoint
+		}
+	}
+
+	// ecdh_shared_secret = input_hash * b_scan * A
+	inputHash := new(btcec.ModNScalar)
+	inputHash.SetByteSlice(chainhash.TaggedHash(silentInputsTag, smallest, sum.SerializeCompressed())[:])
+	var sumJ, shared btcec.JacobianPoint
+	sum.AsJacobian(&sumJ)
+	scalar := new(btcec.ModNScalar).Mul2(inputHash, &scanKey.Key)
+	btcec.ScalarMultNonConst(scalar, &sumJ, &shared)
+	shared.ToAffine()
+	sharedSecret := btcec.NewPublicKey(&shared.X, &shared.Y).SerializeCompressed()
+
+	// Outputs by x-only key
+	outputs := make(map[string]string)
+	for _, out := range tx.Outputs {
+		outBytes, err := hex.DecodeString(out)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output %q: %v", out, err)
+		}
+		if len(outBytes) == 34 && outBytes[0] == 0x51 && outBytes[1] == 0x20 {
+			outBytes = outBytes[2:]
+		}
+		if len(outBytes) == schnorr.PubKeyBytesLen {
+			outputs[string(outBytes)] = hex.EncodeToString(outBytes)
+		}
+	}
+
+	var payments []silentPayment
+	spendPub := spendKey.PubKey()
+	for k := uint32(0); ; k++ {
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], k)
+		tweak := new(btcec.ModNScalar)
+		tweak.SetByteSlice(chainhash.TaggedHash(silentSharedSecretTag, sharedSecret, index[:])[:])
+		pk := addPoints(spendPub, scalarBaseMult(tweak))
+		if pk == nil {
+			break
+		}
+
+		found := false
+		if out, ok := outputs[string(schnorr.SerializePubKey(pk))]; ok {
+			payments = append(payments, silentPayment{output: out, k: k, label: -1, privKey: tweakedKey(spendKey, tweak, nil)})
+			delete(outputs, string(schnorr.SerializePubKey(pk)))
+			found = true
+		} else {
+			// output = P_k + B_m, try both parities of the output key
+			negPk := negate(pk)
+			for key, out := range outputs {
+				outKey, _ := schnorr.ParsePubKey([]byte(key))
+				for _, candidate := range []*btcec.PublicKey{outKey, negate(outKey)} {
+					label := addPoints(candidate, negPk)
+					if label == nil {
+						continue
+					}
+					if l, ok := labels[string(label.SerializeCompressed())]; ok {
+						payments = append(payments, silentPayment{output: out, k: k, label: l.m, privKey: tweakedKey(spendKey, tweak, l.tweak)})
+						delete(outputs, key)
+						found = true
+						break
+					}
+				}
+				if found {
+					break
+				}
+			}
+		}
+		if !found {
+			break
+		}
+		tweakBytes := tweak.Bytes()
+		payments[len(payments)-1].tweakHex = hex.EncodeToString(tweakBytes[:])
+	}
+	return payments, nil
+}
+
+// tweakedKey returns b_spend + t_k (+ label tweak), the private key of a
+// detected output
+func tweakedKey(spendKey *btcec.PrivateKey, tweak, labelTweak *btcec.ModNScalar) *btcec.PrivateKey {
+	key := new(btcec.ModNScalar).Set(&spendKey.Key)
+	key.Add(tweak)
+	if labelTweak != nil {
+		key.Add(labelTweak)
+	}
+	return btcec.PrivKeyFromScalar(key)
+}
+
+// SilentScan detects outputs of the --txs transactions paid to the silent
+// payment keys of --custom_mnemonic, including the outputs with labels
+// 0 (change) to --labels
+func SilentScan() error {
+	if customMnemonic == "" {
+		return errors.New("no mnemonic given, use --custom_mnemonic")
+	}
+	if !bip39.IsMnemonicValid(customMnemonic) {
+		return errors.New("invalid mnemonic phrase")
+	}
+	scanKey, spendKey, err := silentKeys(customMnemonic)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*txsFlag)
+	if err != nil {
+		return err
+	}
+	var txs []silentTx
+	if err := json.Unmarshal(data, &txs); err != nil {
+		return fmt.Errorf("invalid transaction file: %v", err)
+	}
+
+	if *labelsFlag < 0 {
+		return errors.New("invalid label count")
+	}
+	labels := silentLabels(scanKey, *labelsFlag)
+
+	found := 0
+	for _, tx := range txs {
+		payments, err := scanSilentTx(tx, scanKey, spendKey, labels)
+		if err != nil {
+			return fmt.Errorf("transaction %s: %v", tx.TxID, err)
+		}
+		for _, p := range payments {
+			fmt.Printf("%-3s %-12s %s\n", "silent", "txid", tx.TxID)
+			fmt.Printf("%-3s %-12s %s\n", "silent", "output", p.output)
+			fmt.Printf("%-3s %-12s %d\n", "silent", "k", p.k)
+			if p.label >= 0 {
+				fmt.Printf("%-3s %-12s %d\n", "silent", "label", p.label)
+			}
+			fmt.Printf("%-3s %-12s %s\n", "silent", "tweak", p.tweakHex)
+			fmt.Printf("%-3s %-12s %s\n", "silent", "private", hex.EncodeToString(p.privKey.Serialize()))
+			fmt.Println("")
+			found++
+		}
+	}
+	if found == 0 {
+		fmt.Println("no silent payments found")
+	}
+	return nil
+}
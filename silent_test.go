@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// silentTestKeys returns the scan and spend keys of the BIP-352 test vectors
+func silentTestKeys(t *testing.T) (scanKey, spendKey *btcec.PrivateKey) {
+	scan, err := hex.DecodeString("0f694e068028a717f8af6b9411f9a133dd3565258714cc226594b34db90c1f2c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend, err := hex.DecodeString("9d6ad855ce3417ef84e836892e5a56392bfba05fa5d97ccea30e266f540e08b3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanKey, _ = btcec.PrivKeyFromBytes(scan)
+	spendKey, _ = btcec.PrivKeyFromBytes(spend)
+	return scanKey, spendKey
+}
+
+func TestSilentAddress(t *testing.T) {
+	scanKey, spendKey := silentTestKeys(t)
+	address, err := silentAddress(scanKey.PubKey(), spendKey.PubKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "sp1qqgste7k9hx0qftg6qmwlkqtwuy6cycyavzmzj85c6qdfhjdpdjtdgqjuexzk6murw56suy3e0rd2cgqvycxttddwsvgxe2usfpxumr70xc9pkqwv"
+	if address != want {
+		t.Errorf("address %s, want %s", address, want)
+	}
+}
+
+// TestScanSilentTx checks the "Simple send: two inputs" receiving vector
+func TestScanSilentTx(t *testing.T) {
+	scanKey, spendKey := silentTestKeys(t)
+	var tx silentTx
+	err := json.Unmarshal([]byte(`{
+		"inputs": [
+			{"txid": "f4184fc596403b9d638783cf57adfe4c75c605f6356fbc91338530e9831e9e16", "vout": 0,
+				"pubkey": "025a1e61f898173040e20616d43e9f496fba90338a39faa1ed98fcbaeee4dd9be5"},
+			{"txid": "a1075db55d416d3ca199f55b6084e2115b9345e16c5cf302fc80e9d5fbf5d48d", "vout": 0,
+				"pubkey": "03bd85685d03d111699b15d046319febe77f8de5286e9e512703cdee1bf3be3792"}
+		],
+		"outputs": ["3e9fce73d4e77a4809908e3c3a2e54ee147b9312dc5044a193d1fc85de46e3c1"]
+	}`), &tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payments, err := scanSilentTx(tx, scanKey, spendKey, silentLabels(scanKey, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 1 {
+		t.Fatalf("found %d payments, want 1", len(payments))
+	}
+	if payments[0].output != tx.Outputs[0] {
+		t.Errorf("output %s, want %s", payments[0].output, tx.Outputs[0])
+	}
+	if payments[0].tweakHex != "f438b40179a3c4262de12986c0e6cce0634007cdc79c1dcd3e20b9ebc2e7eef6" {
+		t.Errorf("tweak %s, want f438b40179a3c4262de12986c0e6cce0634007cdc79c1dcd3e20b9ebc2e7eef6", payments[0].tweakHex)
+	}
+}
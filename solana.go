@@ -105,6 +105,17 @@ func (sol solana) GenerateKeys() (*KeyPair, error) {
 		wallet = types.NewAccount()
 	}
 
+	// The seed is identified by the fingerprint of its secp256k1 master
+	// key, ed25519 keys have no account xpub
+	var origin *keyOrigin
+	if customPrivate == "" && mnemonic != "" && (*infoFlag || *infoLongFlag) {
+		masterKey, err := bip32.NewMasterKey(bip39.NewSeed(mnemonic, ""))
+		if err != nil {
+			return nil, err
+		}
+		origin = &keyOrigin{fingerprint: fingerprint(masterKey), path: derivationPath}
+	}
+
 	// Create the KeyPair
 	keyPair := &KeyPair{
 		network:        "solana",
@@ -112,6 +123,7 @@ func (sol solana) GenerateKeys() (*KeyPair, error) {
 		public:         wallet.PublicKey.ToBase58(),
 		mnemonic:       mnemonic,
 		derivationPath: derivationPath,
+		origin:         origin,
 	}
 
 	return keyPair, nil
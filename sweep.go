@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// utxo is an unspent output listed in the --utxos file
+type utxo struct {
+	TxID         string `json:"txid"`
+	Vout         uint32 `json:"vout"`
+	Value        int64  `json:"value"` // satoshis
+	ScriptPubKey string `json:"scriptPubKey"`
+}
+
+// Estimated input weights with 72-byte DER signatures
+const (
+	weightP2PKH             = 148 * 4    // outpoint, sequence, 107-byte script
+	weightP2PKHUncompressed = 180 * 4    // 139-byte script
+	weightP2SHP2WPKH        = 64*4 + 108 // 23-byte script, 2-item witness
+	weightP2WPKH            = 41*4 + 108 // empty script, 2-item witness
+	weightP2TR              = 41*4 + 66  // empty script, 1-item witness
+	weightTxOverhead        = 10*4 + 2   // version, counts, lock time, segwit marker
+	dustLimit               = 546
+)
+
+// sweepInput matches pkScript against the address types of wif and
+// returns the name and estimated weight of the input spending it
+func sweepInput(wif *btcutil.WIF, pkScript []byte) (string, int, error) {
+	pubKey := wif.PrivKey.PubKey()
+	uncompressed, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey.SerializeUncompressed()), &chaincfg.MainNetParams)
+	if err != nil {
+		return "", 0, err
+	}
+	if script, _ := txscript.PayToAddrScript(uncompressed); bytes.Equal(script, pkScript) {
+		return "bitcoin legacy (uncompressed)", weightP2PKHUncompressed, nil
+	}
+
+	// The compressed key for every other address type
+	compressed, err := btcutil.NewWIF(wif.PrivKey, &chaincfg.MainNetParams, true)
+	if err != nil {
+		return "", 0, err
+	}
+	weights := map[string]int{
+		"legacy":  weightP2PKH,
+		"segwit":  weightP2SHP2WPKH,
+		"native":  weightP2WPKH,
+		"taproot": weightP2TR,
+	}
+	for _, name := range btcOrder {
+		address, err := btcMap[name].getAddress(compressed)
+		if err != nil {
+			return "", 0, err
+		}
+		if script, _ := txscript.PayToAddrScript(address); bytes.Equal(script, pkScript) {
+			return btcMap[name].name, weights[name], nil
+		}
+	}
+	return "", 0, errors.New("output is not paid to any address of this key")
+}
+
+// minRelayFeeRate is the default minimum relay fee of Bitcoin Core in sat/vB
+const minRelayFeeRate = 1
+
+// Sweep builds and signs a transaction spending every output of the
+// --utxos file with the --custom_private key to the --to address, paying
+// --fee_rate sat/vB. No node is contacted.
+func Sweep() error {
+	if customPrivate == "" {
+		return errors.New("no key given, use --custom_private")
+	}
+	wif, err := btcutil.DecodeWIF(customPrivate)
+	if err != nil {
+		return fmt.Errorf("failed to decode WIF private key: %v", err)
+	}
+	// Nodes do not relay transactions below the minimum relay fee, the
+	// negated test also rejects NaN
+	if !(*feeRateFlag >= minRelayFeeRate) {
+		return fmt.Errorf("fee rate %g sat/vB is below the minimum relay fee of %d sat/vB", *feeRateFlag, minRelayFeeRate)
+	}
+
+	destination, err := btcutil.DecodeAddress(*toFlag, &chaincfg.MainNetParams)
+	if err != nil {
+		return fmt.Errorf("invalid destination address %q: %v", *toFlag, err)
+	}
+	destScript, err := txscript.PayToAddrScript(destination)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*utxosFlag)
+	if err != nil {
+		return err
+	}
+	var utxos []utxo
+	if err := json.Unmarshal(data, &utxos); err != nil {
+		return fmt.Errorf("invalid utxo file: %v", err)
+	}
+	if len(utxos) == 0 {
+		return errors.New("no utxos to sweep")
+	}
+
+	tx := wire.NewMsgTx(2)
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	weight := weightTxOverhead + (8+wire.VarIntSerializeSize(uint64(len(destScript)))+len(destScript))*4
+	var total int64
+	spent := map[wire.OutPoint]bool{}
+	for i, u := range utxos {
+		hash, err := chainhash.NewHashFromStr(u.TxID)
+		if err != nil {
+			return fmt.Errorf("invalid txid of utxo %d: %v", i, err)
+		}
+		// A transaction can not spend an output twice
+		outPoint := wire.NewOutPoint(hash, u.Vout)
+		if spent[*outPoint] {
+			return fmt.Errorf("duplicate utxo %d: %s:%d", i, u.TxID, u.Vout)
+		}
+		spent[*outPoint] = true
+		pkScript, err := hex.DecodeString(u.ScriptPubKey)
+		if err != nil {
+			return fmt.Errorf("invalid scriptPubKey of utxo %d: %v", i, err)
+		}
+		if u.Value <= 0 {
+			return fmt.Errorf("invalid value of utxo %d", i)
+		}
+
+		name, inputWeight, err := sweepInput(wif, pkScript)
+		if err != nil {
+			return fmt.Errorf("utxo %d: %v", i, err)
+		}
+		fmt.Printf("%-3s %-12s %s:%d %d sat (%s, ~%.2f vB)\n", "sweep", "input", u.TxID, u.Vout, u.Value, name, float64(inputWeight)/4)
+
+		txIn := wire.NewTxIn(outPoint, nil, nil)
+		txIn.Sequence = wire.MaxTxInSequenceNum - 2 // signal replace-by-fee
+		tx.AddTxIn(txIn)
+		fetcher.AddPrevOut(*outPoint, wire.NewTxOut(u.Value, pkScript))
+		weight += inputWeight
+		total += u.Value
+	}
+
+	vsize := int64(math.Ceil(float64(weight) / 4))
+	fee := int64(math.Ceil(float64(vsize) * *feeRateFlag))
+	if total-fee < dustLimit {
+		return fmt.Errorf("total %d sat does not cover the fee of %d sat", total, fee)
+	}
+	tx.AddTxOut(wire.NewTxOut(total-fee, destScript))
+
+	for i := range tx.TxIn {
+		if err := signInput(tx, i, fetcher, wif); err != nil {
+			return err
+		}
+	}
+
+	// Check every signature before handing out the transaction
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for i, txIn := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		engine, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, sigHashes, prevOut.Value, fetcher)
+		if err != nil {
+			return err
+		}
+		if err := engine.Execute(); err != nil {
+			return fmt.Errorf("input %d does not verify: %v", i, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return err
+	}
+	actualVsize := (tx.SerializeSizeStripped()*3 + tx.SerializeSize() + 3) / 4
+
+	fmt.Printf("%-3s %-12s %s %d sat\n", "sweep", "output", destination.EncodeAddress(), total-fee)
+	fmt.Printf("%-3s %-12s %d sat (%.2f sat/vB)\n", "sweep", "fee", fee, *feeRateFlag)
+	fmt.Printf("%-3s %-12s %d vB estimated, %d vB signed\n", "sweep", "vsize", vsize, actualVsize)
+	fmt.Printf("%-3s %-12s %s\n", "sweep", "txid", tx.TxHash())
+	fmt.Printf("%-3s %-12s %s\n", "sweep", "raw", hex.EncodeToString(buf.Bytes()))
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// numsKey is the BIP-341 "nothing up my sleeve" point H. Nobody knows its
+// private key, so an output using it as internal key can only be spent
+// through one of its script leaves.
+const numsKey = "50929b74c1a04954b78b4b6035e97a5e078a5a0f28ec96d547bfee9ace803ac0"
+
+// listFlag is a flag that can be given several times
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// getScriptPathAddress builds the Taproot address committing to the
+// --tapleaf scripts. The internal key is the key of wif, or the NUMS point
+// when --nums is set. The Merkle root and a control block for every leaf
+// are returned as details.
+func (btc bitcoin) getScriptPathAddress(wif *btcutil.WIF) (btcutil.Address, []detail, error) {
+	if !btc.isTaproot {
+		return nil, nil, errors.New("tapleaf scripts require the taproot network")
+	}
+
+	internalKey := wif.PrivKey.PubKey()
+	if *numsFlag {
+		keyBytes, _ := hex.DecodeString(numsKey)
+		key, err := schnorr.ParsePubKey(keyBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		internalKey = key
+	}
+
+	var leaves []txscript.TapLeaf
+	for _, leaf := range tapLeafFlag {
+		script, err := parseTapLeaf(leaf)
+		if err != nil {
+			return nil, nil, err
+		}
+		leaves = append(leaves, txscript.NewBaseTapLeaf(script))
+	}
+
+	// Assemble the script tree and tweak the internal key with its root
+	tree := txscript.AssembleTaprootScriptTree(leaves...)
+	rootHash := tree.RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])
+
+	addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), btc.getParams())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	details := []detail{
+		{"internal key", hex.EncodeToString(schnorr.SerializePubKey(internalKey))},
+		{"merkle root", hex.EncodeToString(rootHash[:])},
+	}
+	for i, leaf := range leaves {
+		controlBlock := tree.LeafMerkleProofs[i].ToControlBlock(internalKey)
+		controlBytes, err := controlBlock.ToBytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		details = append(details,
+			detail{fmt.Sprintf("tapleaf %d", i), hex.EncodeToString(leaf.Script)},
+			detail{fmt.Sprintf("control %d", i), hex.EncodeToString(controlBytes)},
+		)
+	}
+
+	return addr, details, nil
+}
+
+// parseTapLeaf parses a tapleaf script given either as hex or as one of the
+// simple policies below, where KEY is an x-only or compressed public key in
+// hex and N is a block height, time or relative block count:
+//
+//	pk(KEY)                 <KEY> OP_CHECKSIG
+//	and(pk(KEY),older(N))   <N> OP_CHECKSEQUENCEVERIFY OP_DROP <KEY> OP_CHECKSIG
+//	and(pk(KEY),after(N))   <N> OP_CHECKLOCKTIMEVERIFY OP_DROP <KEY> OP_CHECKSIG
+func parseTapLeaf(leaf string) ([]byte, error) {
+	leaf = strings.ReplaceAll(leaf, " ", "")
+	if !strings.Contains(leaf, "(") {
+		script, err := hex.DecodeString(leaf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tapleaf script %q: %v", leaf, err)
+		}
+		return script, nil
+	}
+
+	builder := txscript.NewScriptBuilder()
+	keyPolicy := leaf
+	if strings.HasPrefix(leaf, "and(") && strings.HasSuffix(leaf, ")") {
+		parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(leaf, "and("), ")"), "),", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid tapleaf policy %q", leaf)
+		}
+		keyPolicy = parts[0] + ")"
+
+		var opcode byte
+		var lock string
+		switch {
+		case strings.HasPrefix(parts[1], "older(") && strings.HasSuffix(parts[1], ")"):
+			opcode = txscript.OP_CHECKSEQUENCEVERIFY
+			lock = strings.TrimSuffix(strings.TrimPrefix(parts[1], "older("), ")")
+		case strings.HasPrefix(parts[1], "after(") && strings.HasSuffix(parts[1], ")"):
+			opcode = txscript.OP_CHECKLOCKTIMEVERIFY
+			lock = strings.TrimSuffix(strings.TrimPrefix(parts[1], "after("), ")")
+		default:
+			return nil, fmt.Errorf("invalid tapleaf policy %q: expected older(N) or after(N)", leaf)
+		}
+
+		n, err := strconv.ParseUint(lock, 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid tapleaf lock %q", lock)
+		}
+		builder.AddInt64(int64(n)).AddOp(opcode).AddOp(txscript.OP_DROP)
+	}
+
+	if !strings.HasPrefix(keyPolicy, "pk(") || !strings.HasSuffix(keyPolicy, ")") {
+		return nil, fmt.Errorf("invalid tapleaf policy %q", leaf)
+	}
+	key, err := parseXOnlyKey(strings.TrimSuffix(strings.TrimPrefix(keyPolicy, "pk("), ")"))
+	if err != nil {
+		return nil, err
+	}
+	builder.AddData(key).AddOp(txscript.OP_CHECKSIG)
+
+	return builder.Script()
+}
+
+// parseXOnlyKey decodes an x-only or compressed public key from hex
+// and returns its 32-byte x-only form
+func parseXOnlyKey(keyHex string) ([]byte, error) {
+	keyBytes, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %v", keyHex, err)
+	}
+
+	var key *btcec.PublicKey
+	if len(keyBytes) == schnorr.PubKeyBytesLen {
+		key, err = schnorr.ParsePubKey(keyBytes)
+	} else {
+		key, err = btcec.ParsePubKey(keyBytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %v", keyHex, err)
+	}
+
+	return schnorr.SerializePubKey(key), nil
+}